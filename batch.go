@@ -0,0 +1,514 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/chettriyuvraj/query-executor/ycfile"
+)
+
+/*** Batches - optional vectorized execution mode
+Batch nodes exchange column vectors of up to batchSize rows instead of one map-backed Tuple per next() call, so per-row interpretation overhead is paid once per batch
+RowToBatchNode and BatchToRowNode adapt between the two, so a batch subtree can be placed anywhere in a row plan and vice versa ***/
+
+const BATCHSIZE = 1024
+
+type Batch struct {
+	headers   []string
+	columns   [][]interface{} // columns[i] holds the values of headers[i], all columns have the same length
+	selection []int           // rows that are still selected, in order, nil if all rows are
+}
+
+func newBatch(headers []string, capacity int) *Batch {
+	b := &Batch{headers: headers, columns: make([][]interface{}, len(headers))}
+	for i := range b.columns {
+		b.columns[i] = make([]interface{}, 0, capacity)
+	}
+	return b
+}
+
+func (b *Batch) length() int { // rows in the batch, selected or not
+	if len(b.columns) == 0 {
+		return 0
+	}
+	return len(b.columns[0])
+}
+
+func (b *Batch) selectedRows() []int {
+	if b.selection != nil {
+		return b.selection
+	}
+	rows := make([]int, b.length())
+	for i := range rows {
+		rows[i] = i
+	}
+	return rows
+}
+
+func (b *Batch) column(header string) ([]interface{}, error) {
+	for i, h := range b.headers {
+		if h == header {
+			return b.columns[i], nil
+		}
+	}
+	return nil, fmt.Errorf("header %v doesn't exist in batch", header)
+}
+
+func (b *Batch) appendTuple(t Tuple) {
+	for i, header := range b.headers {
+		b.columns[i] = append(b.columns[i], t.data[header])
+	}
+}
+
+func (b *Batch) tuple(row int) Tuple {
+	tuple := Tuple{data: make(map[string]interface{}, len(b.headers))}
+	for i, header := range b.headers {
+		tuple.data[header] = b.columns[i][row]
+	}
+	return tuple
+}
+
+type BatchNode interface { /* batch counterpart of PlanNode, nextBatch returns nil at EOF and never an empty batch */
+	init() error
+	nextBatch() (*Batch, error)
+	close() error
+	reset() error
+	getInputs() ([]BatchNode, error)
+}
+
+func InitBatchNode(bn BatchNode) error {
+	if bn == nil {
+		return nil
+	}
+	if err := bn.init(); err != nil {
+		return err
+	}
+
+	bnChildren, err := bn.getInputs()
+	if err != nil {
+		return err
+	}
+	for _, bnChild := range bnChildren {
+		if err := InitBatchNode(bnChild); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func CloseBatchNode(bn BatchNode) error {
+	if bn == nil {
+		return nil
+	}
+	if err := bn.close(); err != nil {
+		return err
+	}
+
+	bnChildren, err := bn.getInputs()
+	if err != nil {
+		return err
+	}
+	for _, bnChild := range bnChildren {
+		if err := CloseBatchNode(bnChild); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resetBatchNode(bn BatchNode) error {
+	inps, err := bn.getInputs()
+	if err != nil {
+		return err
+	}
+
+	for _, inp := range inps {
+		if err := inp.reset(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// row subtrees below RowToBatchNodes get the query context of the plan the batch subtree is part of
+func setQueryContextBatchNode(bn BatchNode, qctx *QueryContext) {
+	if rtb, ok := bn.(*RowToBatchNode); ok {
+		SetQueryContextPlanNode(rtb.input, qctx)
+		return
+	}
+
+	bnChildren, _ := bn.getInputs()
+	for _, bnChild := range bnChildren {
+		setQueryContextBatchNode(bnChild, qctx)
+	}
+}
+
+/*** Batch YCF File Scan Node ***/
+
+type BatchFileScanNode struct {
+	path      string
+	batchSize int // BATCHSIZE if unset
+	reader    *ycfile.YCFileReader
+	headers   []string
+	eof       bool
+}
+
+func (bfsn *BatchFileScanNode) init() error {
+	reader, err := ycfile.NewYCFileReader(bfsn.path)
+	if err != nil {
+		return err
+	}
+	bfsn.reader, bfsn.eof = reader, false
+
+	return nil
+}
+
+func (bfsn *BatchFileScanNode) nextBatch() (*Batch, error) {
+	if bfsn.eof {
+		return nil, nil
+	}
+
+	batchSize := bfsn.batchSize
+	if batchSize <= 0 {
+		batchSize = BATCHSIZE
+	}
+
+	var batch *Batch
+	for batch == nil || batch.length() < batchSize {
+		record, err := bfsn.reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				bfsn.eof = true
+				break
+			}
+			return nil, err
+		}
+
+		if bfsn.headers == nil { // column names are only known once a record is read
+			for _, pair := range record.Data {
+				bfsn.headers = append(bfsn.headers, pair.Key)
+			}
+		}
+		if batch == nil {
+			batch = newBatch(bfsn.headers, batchSize)
+		}
+		for i, pair := range record.Data {
+			batch.columns[i] = append(batch.columns[i], pair.Val)
+		}
+	}
+
+	return batch, nil
+}
+
+func (bfsn *BatchFileScanNode) close() error {
+	if bfsn.reader == nil { // never initialised
+		return nil
+	}
+	return bfsn.reader.Close()
+}
+
+func (bfsn *BatchFileScanNode) getInputs() ([]BatchNode, error) {
+	return nil, nil
+}
+
+func (bfsn *BatchFileScanNode) reset() error {
+	if err := bfsn.close(); err != nil {
+		return err
+	}
+	return bfsn.init()
+}
+
+/*** Batch Filter Node - narrows the selection vector of each batch, same conditions as FilterNode ***/
+
+type BatchFilterNode struct {
+	header   string
+	operator string
+	cmpValue string
+	inputs   []BatchNode
+}
+
+func (bfn *BatchFilterNode) init() error {
+	return nil
+}
+
+func (bfn *BatchFilterNode) nextBatch() (*Batch, error) {
+	for {
+		batch, err := bfn.inputs[0].nextBatch()
+		if err != nil || batch == nil {
+			return batch, err
+		}
+
+		if bfn.operator != "=" {
+			return batch, nil
+		}
+
+		values, err := batch.column(bfn.header)
+		if err != nil {
+			return nil, fmt.Errorf("header %v doesn't exist to filter", bfn.header)
+		}
+		selection := make([]int, 0, len(values))
+		for _, row := range batch.selectedRows() {
+			if eq, _ := valuesEqual(values[row], bfn.cmpValue); eq {
+				selection = append(selection, row)
+			}
+		}
+
+		if len(selection) == 0 {
+			continue
+		}
+		batch.selection = selection
+		return batch, nil
+	}
+}
+
+func (bfn *BatchFilterNode) close() error {
+	return nil
+}
+
+func (bfn *BatchFilterNode) getInputs() ([]BatchNode, error) {
+	return bfn.inputs, nil
+}
+
+func (bfn *BatchFilterNode) reset() error {
+	return resetBatchNode(bfn)
+}
+
+/*** Batch Projection Node - keeps only the required columns, without copying them ***/
+
+type BatchProjectionNode struct {
+	reqHeaders []string
+	inputs     []BatchNode
+}
+
+func (bpn *BatchProjectionNode) init() error {
+	return nil
+}
+
+func (bpn *BatchProjectionNode) nextBatch() (*Batch, error) {
+	batch, err := bpn.inputs[0].nextBatch()
+	if err != nil || batch == nil {
+		return batch, err
+	}
+
+	projected := &Batch{headers: bpn.reqHeaders, selection: batch.selection}
+	for _, header := range bpn.reqHeaders {
+		values, err := batch.column(header)
+		if err != nil {
+			return nil, err
+		}
+		projected.columns = append(projected.columns, values)
+	}
+	return projected, nil
+}
+
+func (bpn *BatchProjectionNode) close() error {
+	return nil
+}
+
+func (bpn *BatchProjectionNode) getInputs() ([]BatchNode, error) {
+	return bpn.inputs, nil
+}
+
+func (bpn *BatchProjectionNode) reset() error {
+	return resetBatchNode(bpn)
+}
+
+/*** Batch Avg Node - aggregates whole column vectors, supports the same modes as AvgNode ***/
+
+type BatchAvgNode struct {
+	header string
+	mode   int
+	inputs []BatchNode
+}
+
+func (ban *BatchAvgNode) init() error {
+	return nil
+}
+
+func (ban *BatchAvgNode) nextBatch() (*Batch, error) {
+	var total float64
+	count := 0
+	for {
+		batch, err := ban.inputs[0].nextBatch()
+		if err != nil {
+			return nil, err
+		}
+		if batch == nil {
+			break
+		}
+
+		if ban.mode == AGGFINAL {
+			sums, err := batch.column("sum")
+			if err != nil {
+				return nil, err
+			}
+			counts, err := batch.column("count")
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range batch.selectedRows() {
+				sum, okSum := sums[row].(float64)
+				partialCount, okCount := counts[row].(int)
+				if !okSum || !okCount {
+					return nil, fmt.Errorf("final average expects partial sum and count, got %v and %v", sums[row], counts[row])
+				}
+				total += sum
+				count += partialCount
+			}
+			continue
+		}
+
+		values, err := batch.column(ban.header)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range batch.selectedRows() {
+			v, ok, err := averageValue(values[row])
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				total += v
+				count++
+			}
+		}
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+
+	if ban.mode == AGGPARTIAL {
+		return &Batch{headers: []string{"sum", "count"}, columns: [][]interface{}{{total}, {count}}}, nil
+	}
+	return &Batch{headers: []string{"average"}, columns: [][]interface{}{{total / float64(count)}}}, nil
+}
+
+func (ban *BatchAvgNode) close() error {
+	return nil
+}
+
+func (ban *BatchAvgNode) getInputs() ([]BatchNode, error) {
+	return ban.inputs, nil
+}
+
+func (ban *BatchAvgNode) reset() error {
+	return resetBatchNode(ban)
+}
+
+/*** Row To Batch Node - gathers tuples of a row subtree into batches ***/
+
+type RowToBatchNode struct {
+	headers   []string // columns of the batches, taken from the first tuple if unset
+	batchSize int      // BATCHSIZE if unset
+	input     PlanNode
+	eof       bool
+}
+
+func (rtb *RowToBatchNode) init() error {
+	rtb.eof = false
+	return InitPlanNode(rtb.input)
+}
+
+func (rtb *RowToBatchNode) nextBatch() (*Batch, error) {
+	if rtb.eof {
+		return nil, nil
+	}
+
+	batchSize := rtb.batchSize
+	if batchSize <= 0 {
+		batchSize = BATCHSIZE
+	}
+
+	var batch *Batch
+	for batch == nil || batch.length() < batchSize {
+		tuple, err := rtb.input.next()
+		if err != nil {
+			return nil, err
+		}
+		if tuple.data == nil {
+			rtb.eof = true
+			break
+		}
+
+		if rtb.headers == nil {
+			for header := range tuple.data {
+				rtb.headers = append(rtb.headers, header)
+			}
+		}
+		if batch == nil {
+			batch = newBatch(rtb.headers, batchSize)
+		}
+		batch.appendTuple(tuple)
+	}
+
+	return batch, nil
+}
+
+func (rtb *RowToBatchNode) close() error {
+	return ClosePlanNode(rtb.input)
+}
+
+func (rtb *RowToBatchNode) getInputs() ([]BatchNode, error) {
+	return nil, nil
+}
+
+func (rtb *RowToBatchNode) reset() error {
+	rtb.eof = false
+	return rtb.input.reset()
+}
+
+/*** Batch To Row Node - a PlanNode yielding the selected rows of a batch subtree one tuple at a time ***/
+
+type BatchToRowNode struct {
+	input  BatchNode
+	batch  *Batch
+	rows   []int // selected rows of batch yet to be returned
+	inputs []PlanNode
+}
+
+func (btr *BatchToRowNode) init() error {
+	btr.batch, btr.rows = nil, nil
+	return InitBatchNode(btr.input)
+}
+
+func (btr *BatchToRowNode) next() (Tuple, error) {
+	for len(btr.rows) == 0 {
+		batch, err := btr.input.nextBatch()
+		if err != nil {
+			return Tuple{}, err
+		}
+		if batch == nil {
+			return Tuple{}, nil // EOF
+		}
+		btr.batch, btr.rows = batch, batch.selectedRows()
+	}
+
+	row := btr.rows[0]
+	btr.rows = btr.rows[1:]
+	return btr.batch.tuple(row), nil
+}
+
+func (btr *BatchToRowNode) setQueryContext(qctx *QueryContext) {
+	setQueryContextBatchNode(btr.input, qctx)
+}
+
+func (btr *BatchToRowNode) close() error {
+	return CloseBatchNode(btr.input)
+}
+
+func (btr *BatchToRowNode) getInputs() ([]PlanNode, error) {
+	return btr.inputs, nil
+}
+
+func (btr *BatchToRowNode) reset() error {
+	btr.batch, btr.rows = nil, nil
+	if err := btr.input.reset(); err != nil {
+		return err
+	}
+	return resetPlanNode(btr)
+}
+
+func (btr *BatchToRowNode) setInputs(inps []PlanNode) {
+	btr.inputs = inps
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchExecution(t *testing.T) {
+	ratings := Table{headers: []string{"userId", "movieId", "rating"}}
+	for i := 0; i < 2500; i++ {
+		ratings.data = append(ratings.data, map[string]interface{}{"userId": strconv.Itoa(i % 13), "movieId": strconv.Itoa(i % 100), "rating": strconv.Itoa(i % 5)})
+	}
+	path := writeRatingsYCFile(t, ratings)
+
+	qe := QueryExecutor{}
+	rowFilter := func() PlanNode {
+		return &ProjectionNode{reqHeaders: []string{"movieId", "rating"}, inputs: []PlanNode{&FilterNode{header: "userId", operator: "=", cmpValue: "4", inputs: []PlanNode{&FileScanNode{path: path}}}}}
+	}
+	rowAvg := &AvgNode{header: "rating", inputs: []PlanNode{&FilterNode{header: "userId", operator: "=", cmpValue: "4", inputs: []PlanNode{&FileScanNode{path: path}}}}}
+	expectedRows, err := qe.ExecutePlan(&QueryDescriptor{planNode: rowFilter()})
+	require.NoError(t, err)
+	require.Len(t, expectedRows, 192)
+	expectedAvg, err := qe.ExecutePlan(&QueryDescriptor{planNode: rowAvg})
+	require.NoError(t, err)
+
+	for _, batchSize := range []int{1, 7, 0} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			tc := []struct {
+				name     string
+				planNode PlanNode
+				expected []Tuple
+			}{
+				{
+					name: "scan filter projection",
+					planNode: &BatchToRowNode{input: &BatchProjectionNode{reqHeaders: []string{"movieId", "rating"}, inputs: []BatchNode{
+						&BatchFilterNode{header: "userId", operator: "=", cmpValue: "4", inputs: []BatchNode{&BatchFileScanNode{path: path, batchSize: batchSize}}},
+					}}},
+					expected: expectedRows,
+				},
+				{
+					name: "aggregation",
+					planNode: &BatchToRowNode{input: &BatchAvgNode{header: "rating", inputs: []BatchNode{
+						&BatchFilterNode{header: "userId", operator: "=", cmpValue: "4", inputs: []BatchNode{&BatchFileScanNode{path: path, batchSize: batchSize}}},
+					}}},
+					expected: expectedAvg,
+				},
+				{
+					name: "partial and final aggregation",
+					planNode: &BatchToRowNode{input: &BatchAvgNode{mode: AGGFINAL, inputs: []BatchNode{&RowToBatchNode{batchSize: batchSize, input: &BatchToRowNode{input: &BatchAvgNode{header: "rating", mode: AGGPARTIAL, inputs: []BatchNode{
+						&BatchFilterNode{header: "userId", operator: "=", cmpValue: "4", inputs: []BatchNode{&BatchFileScanNode{path: path, batchSize: batchSize}}},
+					}}}}}}},
+					expected: expectedAvg,
+				},
+				{
+					name: "row subtree below a batch subtree",
+					planNode: &BatchToRowNode{input: &BatchProjectionNode{reqHeaders: []string{"movieId", "rating"}, inputs: []BatchNode{
+						&RowToBatchNode{batchSize: batchSize, input: &FilterNode{header: "userId", operator: "=", cmpValue: "4", inputs: []PlanNode{&TableScanNode{table: ratings}}}},
+					}}},
+					expected: expectedRows,
+				},
+			}
+
+			for _, test := range tc {
+				t.Run(test.name, func(t *testing.T) {
+					res, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.planNode})
+					require.NoError(t, err)
+					require.Equal(t, test.expected, res)
+				})
+			}
+		})
+	}
+}
+
+func TestBatchFilterMissingHeader(t *testing.T) {
+	ratings := Table{headers: []string{"userId", "rating"}, data: []map[string]interface{}{{"userId": "1", "rating": "4"}}}
+	qe := QueryExecutor{}
+	_, err := qe.ExecutePlan(&QueryDescriptor{planNode: &BatchToRowNode{input: &BatchFilterNode{header: "movieId", operator: "=", cmpValue: "1", inputs: []BatchNode{&RowToBatchNode{input: &TableScanNode{table: ratings}}}}}})
+	require.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"hash/fnv"
+)
+
+/*** Bloom Filter - built over the join keys of a hash join's build side and pushed down to the probe side, so probe tuples that can't match are dropped before they are partitioned ***/
+
+const BLOOMFILTERBITS = 1 << 20 // default size of a bloom filter, 128KB
+const BLOOMFILTERHASHES = 4
+
+type bloomFilter struct {
+	bits   []uint64
+	hashes int
+}
+
+func newBloomFilter(size int) *bloomFilter {
+	if size <= 0 {
+		size = BLOOMFILTERBITS
+	}
+	return &bloomFilter{bits: make([]uint64, (size+63)/64), hashes: BLOOMFILTERHASHES}
+}
+
+func (bf *bloomFilter) add(key string) {
+	h1, h2 := bloomHashes(key)
+	size := uint64(len(bf.bits) * 64)
+	for i := 0; i < bf.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		bf.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// false means the key was definitely never added, true means it probably was
+func (bf *bloomFilter) mayContain(key string) bool {
+	h1, h2 := bloomHashes(key)
+	size := uint64(len(bf.bits) * 64)
+	for i := 0; i < bf.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		if bf.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// two independent hashes of a key, the k bit positions are derived from these by double hashing
+func bloomHashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum & 0xffffffff, (sum >> 32) | 1
+}
+
+/*** Bloom Probe - a bloom filter pushed down into a filter or scan node, along with the headers forming the key on that side ***/
+
+type bloomProbe struct {
+	filter     *bloomFilter
+	keyHeaders []string
+	skipped    int // number of tuples rejected by the filter
+}
+
+func (bp *bloomProbe) mayMatch(t Tuple) bool {
+	if !hasNullKey(t, bp.keyHeaders) && bp.filter.mayContain(joinKey(t, bp.keyHeaders)) {
+		return true
+	}
+	bp.skipped++
+	return false
+}
+
+// nodes that can discard tuples with a pushed down bloom filter before passing them on
+type bloomFilterSink interface {
+	setBloomProbe(bp *bloomProbe)
+}
+
+// pushes a bloom filter into the probe input if it can evaluate one, false if it can't and the join has to check it itself - nil removes the filter again
+func pushDownBloomProbe(probeInput PlanNode, bp *bloomProbe) bool {
+	sink, ok := probeInput.(bloomFilterSink)
+	if ok {
+		sink.setBloomProbe(bp)
+	}
+	return ok
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+/*** CSV Reader - RFC 4180 records with a configurable delimiter and quote character
+Quoted fields may contain delimiters, doubled quotes and newlines, records end with \n or \r\n and blank lines are skipped
+Errors carry the line number the offending record starts on ***/
+
+type csvReader struct {
+	r          *bufio.Reader
+	delimiter  rune
+	quote      rune
+	line       int // lines read so far
+	field      bytes.Buffer
+	pending    rune // rune read ahead by read, returned by the next readRune if hasPending
+	hasPending bool
+}
+
+type csvParseError struct {
+	line int
+	err  error
+}
+
+func (e *csvParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+func (e *csvParseError) Unwrap() error {
+	return e.err
+}
+
+const (
+	CSVDELIMITER     = ','
+	CSVQUOTE         = '"'
+	csvByteOrderMark = '\uFEFF'
+)
+
+var (
+	errCSVBareQuote      = fmt.Errorf("bare quote in unquoted field")
+	errCSVQuoteNotClosed = fmt.Errorf("quoted field not closed")
+	errCSVAfterQuote     = fmt.Errorf("unexpected character after closing quote")
+	errCSVFieldCount     = fmt.Errorf("wrong number of fields")
+)
+
+func newCSVReader(r io.Reader, delimiter rune, quote rune) (*csvReader, error) {
+	if delimiter == 0 {
+		delimiter = CSVDELIMITER
+	}
+	if quote == 0 {
+		quote = CSVQUOTE
+	}
+	if delimiter == quote {
+		return nil, fmt.Errorf("csv delimiter and quote must differ")
+	}
+	if delimiter == '\n' || delimiter == '\r' || quote == '\n' || quote == '\r' {
+		return nil, fmt.Errorf("csv delimiter and quote can't be line breaks")
+	}
+
+	cr := &csvReader{r: bufio.NewReader(r), delimiter: delimiter, quote: quote}
+	if c, _, err := cr.r.ReadRune(); err == nil && c != csvByteOrderMark { // a leading byte order mark isn't part of the first field
+		cr.r.UnreadRune()
+	}
+	return cr, nil
+}
+
+// returns the fields of the next record and the line it starts on, io.EOF once there are no more records
+func (cr *csvReader) read() ([]string, int, error) {
+	for {
+		c, err := cr.readRune()
+		if err != nil {
+			return nil, cr.line + 1, err
+		}
+		if c == '\n' {
+			cr.line++
+			continue
+		}
+		if c == '\r' && cr.skipLineFeed() {
+			cr.line++
+			continue
+		}
+		cr.pending, cr.hasPending = c, true // skipLineFeed may have read past c, so it can't be unread
+		break
+	}
+
+	start := cr.line + 1
+	record := []string{}
+	for {
+		field, end, err := cr.readField()
+		if err != nil {
+			return nil, start, &csvParseError{line: start, err: err}
+		}
+		record = append(record, field)
+		if end {
+			return record, start, nil
+		}
+	}
+}
+
+// reads one field, end is true if it is the last one of its record
+func (cr *csvReader) readField() (string, bool, error) {
+	cr.field.Reset()
+
+	c, err := cr.readRune()
+	if err == io.EOF {
+		cr.line++
+		return "", true, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if c == cr.quote {
+		return cr.readQuotedField()
+	}
+
+	for {
+		switch {
+		case c == cr.delimiter:
+			return cr.field.String(), false, nil
+		case c == '\n':
+			cr.line++
+			return cr.field.String(), true, nil
+		case c == '\r' && cr.skipLineFeed():
+			cr.line++
+			return cr.field.String(), true, nil
+		case c == cr.quote:
+			return "", false, errCSVBareQuote
+		default:
+			cr.field.WriteRune(c)
+		}
+
+		c, err = cr.readRune()
+		if err == io.EOF {
+			cr.line++
+			return cr.field.String(), true, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+	}
+}
+
+func (cr *csvReader) readQuotedField() (string, bool, error) {
+	for {
+		c, err := cr.readRune()
+		if err == io.EOF {
+			return "", false, errCSVQuoteNotClosed
+		}
+		if err != nil {
+			return "", false, err
+		}
+
+		if c == '\n' {
+			cr.line++
+		}
+		if c != cr.quote {
+			cr.field.WriteRune(c)
+			continue
+		}
+
+		/* A quote either escapes the next one or closes the field */
+		c, err = cr.readRune()
+		switch {
+		case err == io.EOF:
+			cr.line++
+			return cr.field.String(), true, nil
+		case err != nil:
+			return "", false, err
+		case c == cr.quote:
+			cr.field.WriteRune(c)
+		case c == cr.delimiter:
+			return cr.field.String(), false, nil
+		case c == '\n':
+			cr.line++
+			return cr.field.String(), true, nil
+		case c == '\r' && cr.skipLineFeed():
+			cr.line++
+			return cr.field.String(), true, nil
+		default:
+			return "", false, errCSVAfterQuote
+		}
+	}
+}
+
+func (cr *csvReader) readRune() (rune, error) {
+	if cr.hasPending {
+		cr.hasPending = false
+		return cr.pending, nil
+	}
+	c, _, err := cr.r.ReadRune()
+	return c, err
+}
+
+// consumes the \n of a \r\n line break, returns false if the \r isn't followed by one - a \r ending the input is a line break too, as in encoding/csv
+func (cr *csvReader) skipLineFeed() bool {
+	c, _, err := cr.r.ReadRune()
+	if err == io.EOF {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	if c != '\n' {
+		cr.r.UnreadRune()
+		return false
+	}
+	return true
+}
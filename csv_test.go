@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVReader(t *testing.T) {
+	tc := []struct {
+		name      string
+		input     string
+		delimiter rune
+		quote     rune
+		records   [][]string
+		lines     []int
+		errLine   int
+		err       error
+	}{
+		{
+			name:    "plain",
+			input:   "movieId,title\n1,Toy Story (1995)\n",
+			records: [][]string{{"movieId", "title"}, {"1", "Toy Story (1995)"}},
+			lines:   []int{1, 2},
+		},
+		{
+			name:    "quoted delimiter",
+			input:   "11,\"American President, The (1995)\",Comedy|Drama|Romance\r\n",
+			records: [][]string{{"11", "American President, The (1995)", "Comedy|Drama|Romance"}},
+			lines:   []int{1},
+		},
+		{
+			name:    "escaped quotes, embedded newlines and blank lines",
+			input:   "1,\"say \"\"hi\"\"\"\n\n2,\"two\nlines\",\r\n3,,\"\"",
+			records: [][]string{{"1", "say \"hi\""}, {"2", "two\nlines", ""}, {"3", "", ""}},
+			lines:   []int{1, 3, 5},
+		},
+		{
+			name:    "bare carriage return starting a record",
+			input:   "1,a\r\n\rb,c\n\r,d\n",
+			records: [][]string{{"1", "a"}, {"\rb", "c"}, {"\r", "d"}},
+			lines:   []int{1, 2, 3},
+		},
+		{
+			name:    "carriage return at the end of the input ends its record",
+			input:   "a,b\n1,2\r",
+			records: [][]string{{"a", "b"}, {"1", "2"}},
+			lines:   []int{1, 2},
+		},
+		{
+			name:    "carriage return at the end of the input after a quoted field",
+			input:   "a,\"b\"\r",
+			records: [][]string{{"a", "b"}},
+			lines:   []int{1},
+		},
+		{
+			name:    "carriage return alone at the end of the input",
+			input:   "a\n\r",
+			records: [][]string{{"a"}},
+			lines:   []int{1},
+		},
+		{
+			name:      "custom delimiter and quote",
+			input:     "\uFEFF1;'a;b';'it''s'\n2;c;d",
+			delimiter: ';',
+			quote:     '\'',
+			records:   [][]string{{"1", "a;b", "it's"}, {"2", "c", "d"}},
+			lines:     []int{1, 2},
+		},
+		{
+			name:    "bare quote",
+			input:   "1,ok\n2,bad\"quote\n",
+			records: [][]string{{"1", "ok"}},
+			lines:   []int{1},
+			errLine: 2,
+			err:     errCSVBareQuote,
+		},
+		{
+			name:    "unclosed quote",
+			input:   "1,ok\n\n2,\"never\nclosed\n",
+			records: [][]string{{"1", "ok"}},
+			lines:   []int{1},
+			errLine: 3,
+			err:     errCSVQuoteNotClosed,
+		},
+		{
+			name:    "text after closing quote",
+			input:   "1,\"a\"b\n",
+			errLine: 1,
+			err:     errCSVAfterQuote,
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cr, err := newCSVReader(strings.NewReader(test.input), test.delimiter, test.quote)
+			require.NoError(t, err)
+
+			records, lines := [][]string{}, []int{}
+			for {
+				record, line, err := cr.read()
+				if err == io.EOF {
+					break
+				}
+				if test.err != nil && err != nil {
+					require.ErrorIs(t, err, test.err)
+					var parseErr *csvParseError
+					require.True(t, errors.As(err, &parseErr))
+					require.Equal(t, test.errLine, parseErr.line)
+					break
+				}
+				require.NoError(t, err)
+				records, lines = append(records, record), append(lines, line)
+			}
+			if test.records == nil {
+				test.records, test.lines = [][]string{}, []int{}
+			}
+			require.Equal(t, test.records, records)
+			require.Equal(t, test.lines, lines)
+		})
+	}
+}
+
+func TestCSVScanNode(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0666))
+		return path
+	}
+	movies := "movieId,title,genres\n11,\"American President, The (1995)\",Comedy|Drama|Romance\n12,Dracula: Dead and Loving It (1995),Comedy|Horror\n"
+
+	tc := []struct {
+		name     string
+		node     *CSVScanNode
+		expected []Tuple
+		err      string
+	}{
+		{
+			name: "header",
+			node: &CSVScanNode{path: writeFile("movies.csv", movies)},
+			expected: []Tuple{
+				{data: map[string]interface{}{"movieId": "11", "title": "American President, The (1995)", "genres": "Comedy|Drama|Romance"}},
+				{data: map[string]interface{}{"movieId": "12", "title": "Dracula: Dead and Loving It (1995)", "genres": "Comedy|Horror"}},
+			},
+		},
+		{
+			name: "headerless",
+			node: &CSVScanNode{path: writeFile("ratings.tsv", "1\t11\t4.0\n2\t12\t3.5\n"), headerless: true, delimiter: '\t'},
+			expected: []Tuple{
+				{data: map[string]interface{}{"column1": "1", "column2": "11", "column3": "4.0"}},
+				{data: map[string]interface{}{"column1": "2", "column2": "12", "column3": "3.5"}},
+			},
+		},
+		{
+			name: "headerless with headers",
+			node: &CSVScanNode{path: writeFile("ratings.csv", "1,11,4.0\n"), headerless: true, headers: []string{"userId", "movieId", "rating"}},
+			expected: []Tuple{
+				{data: map[string]interface{}{"userId": "1", "movieId": "11", "rating": "4.0"}},
+			},
+		},
+		{
+			name: "short row",
+			node: &CSVScanNode{path: writeFile("short.csv", "movieId,title,genres\n11,ok,Comedy\n\n12,missing genres\n")},
+			err:  "short.csv: line 4: wrong number of fields: expected 3, got 2",
+		},
+		{
+			name: "no header",
+			node: &CSVScanNode{path: writeFile("empty.csv", "")},
+			err:  "empty.csv: no header row found",
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			qe := QueryExecutor{}
+			for run := 0; run < 2; run++ { // the second run checks reset
+				if run == 1 && test.err == "" {
+					require.NoError(t, InitPlanNode(test.node))
+					require.NoError(t, test.node.reset())
+					require.NoError(t, ClosePlanNode(test.node))
+				}
+				res, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.node})
+				if test.err != "" {
+					require.ErrorContains(t, err, test.err)
+					return
+				}
+				require.NoError(t, err)
+				require.Equal(t, test.expected, res)
+			}
+		})
+	}
+}
+
+func TestHashJoinQuotedValues(t *testing.T) {
+	movies := Table{headers: []string{"movieId", "title"}, data: []map[string]interface{}{
+		{"movieId": "11", "title": "American President, The (1995)"},
+		{"movieId": "12", "title": "a \"quoted\"\ntitle"},
+	}}
+	ratings := Table{headers: []string{"userId", "movieId"}, data: []map[string]interface{}{
+		{"userId": "1", "movieId": "11"},
+		{"userId": "2", "movieId": "12"},
+	}}
+
+	qe := QueryExecutor{}
+	res, err := qe.ExecutePlan(&QueryDescriptor{
+		planNode: &HashJoinNode{reqHeaders: []string{"movieId", "movieId"}, partitionCount: 2, headersInOrder: [][]string{movies.headers, ratings.headers}, inputs: []PlanNode{&TableScanNode{table: movies}, &CSVScanNode{path: writeRatingsCSV(t, ratings)}}},
+		qctx:     &QueryContext{spill: NewSpillManager(t.TempDir(), 0)},
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Tuple{
+		{data: map[string]interface{}{"movieId": "11", "title": "American President, The (1995)", "userId": "1"}},
+		{data: map[string]interface{}{"movieId": "12", "title": "a \"quoted\"\ntitle", "userId": "2"}},
+	}, res)
+}
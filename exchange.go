@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+/*** Exchange Node - gathers the output of its inputs, each run in a goroutine of its own
+Inputs are usually copies of the same subtree over different partitions of the data, e.g. FileScanNodes over ranges of a YCFile or hash join workers over hash partitioned streams
+Tuples are passed through a bounded channel, so workers block once they are bufferSize tuples ahead of the consumer ***/
+
+const EXCHANGEBUFFERSIZE = 64
+
+type ExchangeNode struct {
+	bufferSize int // tuples buffered between the workers and the consumer, EXCHANGEBUFFERSIZE if unset
+	inputs     []PlanNode
+	out        chan exchangeMessage
+	done       chan struct{} // closed to stop the workers
+	wg         sync.WaitGroup
+	running    int   // workers yet to finish
+	err        error // first error of a worker, returned by every call to next after it
+	started    bool
+}
+
+type exchangeMessage struct {
+	tuple Tuple
+	err   error
+	eof   bool // the worker has no more tuples
+}
+
+func (en *ExchangeNode) init() error {
+	return nil
+}
+
+func (en *ExchangeNode) next() (Tuple, error) {
+	if !en.started {
+		en.start()
+	}
+
+	if en.err != nil {
+		return Tuple{}, en.err
+	}
+
+	for en.running > 0 {
+		msg := <-en.out
+		if msg.err != nil { // the worker stops without sending eof
+			en.running--
+			en.err = msg.err
+			return Tuple{}, msg.err
+		}
+		if msg.eof {
+			en.running--
+			continue
+		}
+		return msg.tuple, nil
+	}
+
+	return Tuple{}, nil
+}
+
+// starts one worker per input, inputs have already been initialised by the executor
+func (en *ExchangeNode) start() {
+	bufferSize := en.bufferSize
+	if bufferSize <= 0 {
+		bufferSize = EXCHANGEBUFFERSIZE
+	}
+
+	en.out, en.done = make(chan exchangeMessage, bufferSize), make(chan struct{})
+	en.running, en.err, en.started = len(en.inputs), nil, true
+	for _, inp := range en.inputs {
+		en.wg.Add(1)
+		go en.work(inp)
+	}
+}
+
+func (en *ExchangeNode) work(inp PlanNode) {
+	defer en.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			en.send(exchangeMessage{err: fmt.Errorf("exchange worker panicked: %v", r)})
+		}
+	}()
+
+	for {
+		tuple, err := inp.next()
+		if err != nil {
+			en.send(exchangeMessage{err: err})
+			return
+		}
+		if tuple.data == nil {
+			en.send(exchangeMessage{eof: true})
+			return
+		}
+		if !en.send(exchangeMessage{tuple: tuple}) {
+			return
+		}
+	}
+}
+
+// blocks until the consumer takes the message or the exchange is stopped, returns false if stopped
+func (en *ExchangeNode) send(msg exchangeMessage) bool {
+	select {
+	case en.out <- msg:
+		return true
+	case <-en.done:
+		return false
+	}
+}
+
+// stops the workers and waits for them to return, so the inputs can be closed or reset safely
+func (en *ExchangeNode) stop() {
+	if !en.started {
+		return
+	}
+	en.cancel()
+	en.wg.Wait()
+	en.started = false
+}
+
+// unblocks the workers, along with anything in their subtrees blocked on other goroutines
+func (en *ExchangeNode) cancel() {
+	if !en.started {
+		return
+	}
+	select {
+	case <-en.done:
+	default:
+		close(en.done)
+	}
+	for _, inp := range en.inputs {
+		CancelPlanNode(inp)
+	}
+}
+
+func (en *ExchangeNode) close() error {
+	en.stop()
+	return nil
+}
+
+func (en *ExchangeNode) getInputs() ([]PlanNode, error) {
+	return en.inputs, nil
+}
+
+func (en *ExchangeNode) reset() error {
+	en.stop()
+	return resetPlanNode(en)
+}
+
+func (en *ExchangeNode) setInputs(inps []PlanNode) {
+	en.inputs = inps
+}
+
+// nodes that run work on other goroutines stop it when cancelled, so whatever is blocked on them returns
+type cancelableNode interface {
+	cancel()
+}
+
+func CancelPlanNode(pn PlanNode) {
+	if pn == nil {
+		return
+	}
+
+	if cn, ok := pn.(cancelableNode); ok {
+		cn.cancel()
+		return // cancels its own subtree
+	}
+
+	pnChildren, _ := pn.getInputs()
+	for _, pnChild := range pnChildren {
+		CancelPlanNode(pnChild)
+	}
+}
+
+/*** Hash Partitioned Streams - splits the output of one input into partitionCount streams on a hash of keyHeaders, e.g. to feed one hash join worker per stream
+A producer goroutine pulls the input and routes each tuple to the bounded channel of its stream, the shared input is initialised by the first stream and closed by the last ***/
+
+type hashPartitioner struct {
+	input      PlanNode
+	keyHeaders []string
+	streams    []chan exchangeMessage
+	done       chan struct{}
+	initOnce   sync.Once
+	startOnce  sync.Once
+	cancelOnce sync.Once
+	wg         sync.WaitGroup
+	initErr    error
+	mu         sync.Mutex
+	open       int // streams yet to be closed
+	qctxOnce   sync.Once
+}
+
+// returns partitionCount plan nodes, the ith yielding the tuples of input whose keys hash to partition i
+func NewHashPartitionedStreams(input PlanNode, keyHeaders []string, partitionCount int, bufferSize int) []PlanNode {
+	if bufferSize <= 0 {
+		bufferSize = EXCHANGEBUFFERSIZE
+	}
+
+	hp := &hashPartitioner{input: input, keyHeaders: keyHeaders, done: make(chan struct{}), open: partitionCount}
+	streams := make([]PlanNode, partitionCount)
+	for i := range streams {
+		hp.streams = append(hp.streams, make(chan exchangeMessage, bufferSize))
+		streams[i] = &hashStreamNode{partitioner: hp, partition: i}
+	}
+	return streams
+}
+
+func (hp *hashPartitioner) produce() {
+	defer hp.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			hp.broadcast(exchangeMessage{err: fmt.Errorf("hash partitioner panicked: %v", r)})
+		}
+	}()
+
+	for {
+		tuple, err := hp.input.next()
+		if err != nil {
+			hp.broadcast(exchangeMessage{err: err})
+			return
+		}
+		if tuple.data == nil {
+			hp.broadcast(exchangeMessage{eof: true})
+			return
+		}
+
+		partitionIdx := partitionOf(joinKey(tuple, hp.keyHeaders), 0, len(hp.streams))
+		select {
+		case hp.streams[partitionIdx] <- exchangeMessage{tuple: tuple}:
+		case <-hp.done:
+			return
+		}
+	}
+}
+
+func (hp *hashPartitioner) broadcast(msg exchangeMessage) {
+	for _, stream := range hp.streams {
+		select {
+		case stream <- msg:
+		case <-hp.done:
+			return
+		}
+	}
+}
+
+func (hp *hashPartitioner) cancel() {
+	hp.cancelOnce.Do(func() {
+		close(hp.done)
+		CancelPlanNode(hp.input)
+	})
+}
+
+/*** Hash Stream Node - one stream of a hashPartitioner ***/
+
+type hashStreamNode struct {
+	partitioner *hashPartitioner
+	partition   int
+	eof         bool
+	closed      bool
+	inputs      []PlanNode // always empty, the shared input is managed by the partitioner
+}
+
+func (hsn *hashStreamNode) init() error {
+	hp := hsn.partitioner
+	hp.initOnce.Do(func() {
+		hp.initErr = InitPlanNode(hp.input)
+	})
+	return hp.initErr
+}
+
+func (hsn *hashStreamNode) next() (Tuple, error) {
+	hp := hsn.partitioner
+	if hsn.eof {
+		return Tuple{}, nil
+	}
+
+	hp.startOnce.Do(func() {
+		hp.wg.Add(1)
+		go hp.produce()
+	})
+
+	select {
+	case msg := <-hp.streams[hsn.partition]:
+		if msg.err != nil {
+			return Tuple{}, msg.err
+		}
+		if msg.eof {
+			hsn.eof = true
+			return Tuple{}, nil
+		}
+		return msg.tuple, nil
+	case <-hp.done:
+		return Tuple{}, fmt.Errorf("hash partitioned stream cancelled")
+	}
+}
+
+// the last stream to close stops the producer and closes the shared input
+func (hsn *hashStreamNode) close() error {
+	hp := hsn.partitioner
+	if hsn.closed {
+		return nil
+	}
+	hsn.closed = true
+
+	hp.mu.Lock()
+	hp.open--
+	last := hp.open == 0
+	hp.mu.Unlock()
+
+	if !last {
+		return nil
+	}
+	hp.cancel()
+	hp.wg.Wait()
+	return ClosePlanNode(hp.input)
+}
+
+func (hsn *hashStreamNode) cancel() {
+	hsn.partitioner.cancel()
+}
+
+func (hsn *hashStreamNode) setQueryContext(qctx *QueryContext) {
+	hp := hsn.partitioner
+	hp.qctxOnce.Do(func() {
+		SetQueryContextPlanNode(hp.input, qctx)
+	})
+}
+
+func (hsn *hashStreamNode) getInputs() ([]PlanNode, error) {
+	return hsn.inputs, nil
+}
+
+func (hsn *hashStreamNode) reset() error {
+	return fmt.Errorf("hash partitioned streams can't be rescanned")
+}
+
+func (hsn *hashStreamNode) setInputs(inps []PlanNode) {
+	hsn.inputs = inps
+}
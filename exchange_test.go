@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chettriyuvraj/query-executor/ycfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExchangeFileScanRanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratings.ycf")
+	require.NoError(t, ycfile.CreateYCFile(path, []string{"userId", "rating"}, []byte{ycfile.STRINGSMALL, ycfile.STRINGSMALL}))
+	writer, err := ycfile.NewYCFileWriter(path)
+	require.NoError(t, err)
+	for i := 0; i < 1001; i++ {
+		record := ycfile.YCFileRecord{Data: []ycfile.StringPair{{Key: "userId", Val: strconv.Itoa(i % 10)}, {Key: "rating", Val: strconv.Itoa(i % 5)}}}
+		require.NoError(t, writer.Write(record))
+	}
+	require.NoError(t, writer.Close())
+
+	qe := QueryExecutor{}
+	serial, err := qe.ExecutePlan(&QueryDescriptor{planNode: &FilterNode{header: "userId", operator: "=", cmpValue: "3", inputs: []PlanNode{&FileScanNode{path: path}}}})
+	require.NoError(t, err)
+	require.Len(t, serial, 100)
+
+	for _, workers := range []int{1, 3, 4, 8} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			filtered, partialAvgs := []PlanNode{}, []PlanNode{}
+			for i := 0; i < workers; i++ {
+				filtered = append(filtered, &FilterNode{header: "userId", operator: "=", cmpValue: "3", inputs: []PlanNode{&FileScanNode{path: path, partition: i, partitionCount: workers}}})
+				partialAvgs = append(partialAvgs, &AvgNode{header: "rating", mode: AGGPARTIAL, inputs: []PlanNode{&FileScanNode{path: path, partition: i, partitionCount: workers}}})
+			}
+
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: &ExchangeNode{bufferSize: 4, inputs: filtered}})
+			require.NoError(t, err)
+			require.ElementsMatch(t, serial, res)
+
+			res, err = qe.ExecutePlan(&QueryDescriptor{planNode: &AvgNode{mode: AGGFINAL, inputs: []PlanNode{&ExchangeNode{inputs: partialAvgs}}}})
+			require.NoError(t, err)
+			require.Len(t, res, 1)
+			require.InDelta(t, 2000.0/1001.0, res[0].data["average"], 1e-9)
+		})
+	}
+}
+
+func TestExchangeHashJoin(t *testing.T) {
+	movies, ratings := syntheticMovieRatings(200, 2000, 300)
+	headers := []string{"movieId", "movieId"}
+	headersInOrder := [][]string{movies.headers, ratings.headers}
+
+	qe := QueryExecutor{}
+	serial, err := qe.ExecutePlan(&QueryDescriptor{
+		planNode: &HashJoinNode{reqHeaders: headers, partitionCount: 4, headersInOrder: headersInOrder, inputs: []PlanNode{&TableScanNode{table: movies}, &TableScanNode{table: ratings}}},
+		qctx:     &QueryContext{spill: NewSpillManager(t.TempDir(), 0)},
+	})
+	require.NoError(t, err)
+	require.Len(t, serial, 1400)
+
+	const workers = 4
+	movieStreams := NewHashPartitionedStreams(&TableScanNode{table: movies}, []string{"movieId"}, workers, 8)
+	ratingStreams := NewHashPartitionedStreams(&TableScanNode{table: ratings}, []string{"movieId"}, workers, 8)
+	joins := []PlanNode{}
+	for i := 0; i < workers; i++ {
+		joins = append(joins, &HashJoinNode{reqHeaders: headers, partitionCount: 4, hybrid: i%2 == 0, headersInOrder: headersInOrder, inputs: []PlanNode{movieStreams[i], ratingStreams[i]}})
+	}
+
+	spillDir := t.TempDir()
+	res, err := qe.ExecutePlan(&QueryDescriptor{planNode: &ExchangeNode{inputs: joins}, qctx: &QueryContext{spill: NewSpillManager(spillDir, 0)}})
+	require.NoError(t, err)
+	require.ElementsMatch(t, serial, res)
+
+	entries, err := os.ReadDir(spillDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestExchangeStopsWorkers(t *testing.T) {
+	_, ratings := syntheticMovieRatings(0, 5000, 100)
+
+	tc := []struct {
+		name    string
+		workers func() []PlanNode
+		limit   int
+		err     bool
+	}{
+		{
+			name:  "limit over scans",
+			limit: 5,
+			workers: func() []PlanNode {
+				return []PlanNode{&TableScanNode{table: ratings}, &TableScanNode{table: ratings}, &TableScanNode{table: ratings}}
+			},
+		},
+		{
+			name:  "limit over hash partitioned streams",
+			limit: 5,
+			workers: func() []PlanNode {
+				return NewHashPartitionedStreams(&TableScanNode{table: ratings}, []string{"movieId"}, 3, 1)
+			},
+		},
+		{
+			name:  "worker error",
+			limit: 10000,
+			err:   true,
+			workers: func() []PlanNode {
+				return []PlanNode{&TableScanNode{table: ratings}, &FilterNode{header: "missing", operator: "=", cmpValue: "1", inputs: []PlanNode{&TableScanNode{table: ratings}}}}
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			exchange := &ExchangeNode{bufferSize: 1, inputs: test.workers()}
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: &LimitNode{limit: test.limit, inputs: []PlanNode{exchange}}})
+			if test.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, res, test.limit)
+			}
+		})
+	}
+
+	require.Eventually(t, func() bool {
+		buf := make([]byte, 1<<20)
+		stacks := string(buf[:runtime.Stack(buf, true)])
+		return !strings.Contains(stacks, "(*ExchangeNode).work") && !strings.Contains(stacks, "(*hashPartitioner).produce")
+	}, time.Second, 10*time.Millisecond, "exchange workers must stop once the plan is closed")
+}
+
+func TestExchangeWorkerError(t *testing.T) {
+	_, ratings := syntheticMovieRatings(0, 100, 10)
+	exchange := &ExchangeNode{inputs: []PlanNode{
+		&TableScanNode{table: ratings},
+		&FilterNode{header: "missing", operator: "=", cmpValue: "1", inputs: []PlanNode{&TableScanNode{table: ratings}}},
+	}}
+	require.NoError(t, InitPlanNode(exchange))
+	defer ClosePlanNode(exchange)
+
+	var firstErr error
+	for firstErr == nil {
+		_, firstErr = exchange.next()
+	}
+
+	// the failed worker never sends eof, later calls must not wait for it
+	errs := make(chan error)
+	go func() {
+		for i := 0; i < 3; i++ {
+			_, err := exchange.next()
+			errs <- err
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			require.Equal(t, firstErr, err)
+		case <-time.After(time.Second):
+			t.Fatal("next blocked after a worker error")
+		}
+	}
+
+	// a reset clears the error along with the workers
+	exchange.inputs[1] = &TableScanNode{table: ratings}
+	require.NoError(t, exchange.reset())
+	count := 0
+	for {
+		tuple, err := exchange.next()
+		require.NoError(t, err)
+		if tuple.data == nil {
+			break
+		}
+		count++
+	}
+	require.Equal(t, 200, count)
+}
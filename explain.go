@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*** Explain - describes an optimised plan as a tree of its nodes, one per line and indented under their parent ***/
+
+// nodes that describe what they do beyond their type name, e.g. the files a scan reads
+type explainNode interface {
+	explain() (string, error)
+}
+
+// optimises the plan as InitPlan would and describes it without executing it
+func (qe *QueryExecutor) Explain(qd *QueryDescriptor) (string, error) {
+	planNode, err := OptimizePlan(qd.planNode)
+	if err != nil {
+		return "", err
+	}
+	qd.planNode = planNode
+	return ExplainPlanNode(planNode)
+}
+
+func ExplainPlanNode(pn PlanNode) (string, error) {
+	sb := strings.Builder{}
+	err := explainPlanNode(&sb, pn, 0)
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func explainPlanNode(sb *strings.Builder, pn PlanNode, depth int) error {
+	if pn == nil {
+		return nil
+	}
+
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(reflect.TypeOf(pn).Elem().Name())
+	if en, ok := pn.(explainNode); ok {
+		details, err := en.explain()
+		if err != nil {
+			return err
+		}
+		if details != "" {
+			sb.WriteString(" " + details)
+		}
+	}
+	sb.WriteString("\n")
+
+	pnChildren, err := pn.getInputs()
+	if err != nil {
+		return err
+	}
+	for _, pnChild := range pnChildren {
+		err := explainPlanNode(sb, pnChild, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fn *FilterNode) explain() (string, error) {
+	if fn.header == "" { // only filters with a pushed down bloom filter
+		return "", nil
+	}
+	return fmt.Sprintf("%v %v %v", fn.header, fn.operator, fn.cmpValue), nil
+}
+
+func (pn *ProjectionNode) explain() (string, error) {
+	return fmt.Sprint(pn.reqHeaders), nil
+}
+
+func (ln *LimitNode) explain() (string, error) {
+	return fmt.Sprint(ln.limit), nil
+}
+
+func (csvn *CSVScanNode) explain() (string, error) {
+	return explainSource(csvn.path, csvn.filters, csvn.columns)
+}
+
+func (jsn *JSONLScanNode) explain() (string, error) {
+	return explainSource(jsn.path, jsn.filters, jsn.columns)
+}
+
+func (fsn *FileScanNode) explain() (string, error) {
+	return explainSource(fsn.path, fsn.filters, fsn.columns)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*** Expressions - predicates evaluated against a row, e.g. join conditions like r.timestamp BETWEEN m.start AND m.end ***/
+
+type Expr interface {
+	eval(row Row) (interface{}, error)
+}
+
+// Row is anything an expression can look up headers in - a single tuple or a pair of tuples being joined
+type Row interface {
+	get(header string) (interface{}, bool)
+}
+
+func (t Tuple) get(header string) (interface{}, bool) {
+	v, exists := t.data[header]
+	return v, exists
+}
+
+// joinedRow looks up headers in both tuples of a candidate join pair without combining them
+type joinedRow struct {
+	left  Tuple
+	right Tuple
+}
+
+func (jr joinedRow) get(header string) (interface{}, bool) {
+	if v, exists := jr.left.data[header]; exists {
+		return v, true
+	}
+	return jr.right.get(header)
+}
+
+type ColumnExpr struct {
+	header string
+}
+
+func (ce *ColumnExpr) eval(row Row) (interface{}, error) {
+	v, exists := row.get(ce.header)
+	if !exists {
+		return nil, fmt.Errorf("header %v doesn't exist to evaluate", ce.header)
+	}
+	return v, nil
+}
+
+type ConstExpr struct {
+	value interface{}
+}
+
+func (ce *ConstExpr) eval(row Row) (interface{}, error) {
+	return ce.value, nil
+}
+
+type CmpExpr struct {
+	operator string // one of =, !=, <, <=, >, >=
+	left     Expr
+	right    Expr
+}
+
+func (ce *CmpExpr) eval(row Row) (interface{}, error) {
+	l, err := ce.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ce.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	return compareWithOperator(ce.operator, l, r)
+}
+
+type BetweenExpr struct { // value BETWEEN low AND high, bounds inclusive
+	value Expr
+	low   Expr
+	high  Expr
+}
+
+func (be *BetweenExpr) eval(row Row) (interface{}, error) {
+	return (&AndExpr{exprs: []Expr{
+		&CmpExpr{operator: ">=", left: be.value, right: be.low},
+		&CmpExpr{operator: "<=", left: be.value, right: be.high},
+	}}).eval(row)
+}
+
+type AndExpr struct {
+	exprs []Expr
+}
+
+func (ae *AndExpr) eval(row Row) (interface{}, error) {
+	for _, e := range ae.exprs {
+		ok, err := evalPredicate(e, row)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+type OrExpr struct {
+	exprs []Expr
+}
+
+func (oe *OrExpr) eval(row Row) (interface{}, error) {
+	for _, e := range oe.exprs {
+		ok, err := evalPredicate(e, row)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// a predicate holds only if it evaluates to true, anything else (false, NULL) rejects the row
+func evalPredicate(e Expr, row Row) (bool, error) {
+	v, err := e.eval(row)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	return ok && b, nil
+}
+
+// headers an expression reads, ok is false if it contains expressions whose headers aren't known
+func exprColumns(e Expr) (columns []string, ok bool) {
+	switch ex := e.(type) {
+	case *ColumnExpr:
+		return []string{ex.header}, true
+	case *ConstExpr:
+		return []string{}, true
+	case *CmpExpr:
+		return exprListColumns(ex.left, ex.right)
+	case *BetweenExpr:
+		return exprListColumns(ex.value, ex.low, ex.high)
+	case *AndExpr:
+		return exprListColumns(ex.exprs...)
+	case *OrExpr:
+		return exprListColumns(ex.exprs...)
+	}
+	return nil, false
+}
+
+func exprListColumns(exprs ...Expr) ([]string, bool) {
+	columns := []string{}
+	for _, e := range exprs {
+		c, ok := exprColumns(e)
+		if !ok {
+			return nil, false
+		}
+		columns = append(columns, c...)
+	}
+	return columns, true
+}
+
+// flattens nested ANDs into a list of conjuncts
+func conjuncts(e Expr) []Expr {
+	ae, ok := e.(*AndExpr)
+	if !ok {
+		return []Expr{e}
+	}
+	res := []Expr{}
+	for _, c := range ae.exprs {
+		res = append(res, conjuncts(c)...)
+	}
+	return res
+}
+
+// splits a join predicate into equalities between a left and a right header, usable as hash keys, and the residual conjuncts that must be checked on every candidate pair
+func splitEquiConjuncts(pred Expr, leftHeaders []string, rightHeaders []string) ([]string, []string, Expr) {
+	leftKeys, rightKeys, residual := []string{}, []string{}, []Expr{}
+	for _, c := range conjuncts(pred) {
+		ce, ok := c.(*CmpExpr)
+		if ok && ce.operator == "=" {
+			l, lok := ce.left.(*ColumnExpr)
+			r, rok := ce.right.(*ColumnExpr)
+			if lok && rok {
+				if searchStringInList(l.header, leftHeaders) != -1 && searchStringInList(r.header, rightHeaders) != -1 {
+					leftKeys, rightKeys = append(leftKeys, l.header), append(rightKeys, r.header)
+					continue
+				}
+				if searchStringInList(r.header, leftHeaders) != -1 && searchStringInList(l.header, rightHeaders) != -1 {
+					leftKeys, rightKeys = append(leftKeys, r.header), append(rightKeys, l.header)
+					continue
+				}
+			}
+		}
+		residual = append(residual, c)
+	}
+
+	if len(residual) == 0 {
+		return leftKeys, rightKeys, nil
+	}
+	return leftKeys, rightKeys, &AndExpr{exprs: residual}
+}
+
+/*** Value comparison ***/
+
+func compareWithOperator(operator string, l interface{}, r interface{}) (interface{}, error) {
+	if operator == "=" || operator == "!=" {
+		eq, comparable := valuesEqual(l, r)
+		if !comparable {
+			return nil, nil // NULL
+		}
+		return eq == (operator == "="), nil
+	}
+
+	cmp, comparable := compareValues(l, r)
+	if !comparable {
+		return nil, nil
+	}
+
+	switch operator {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %v", operator)
+}
+
+// values that are (or parse as) numbers compare as numbers, a timestamp or a bool with a string parsed as one and other strings as text - NULLs and values that can't be compared are never equal to anything
+func valuesEqual(a interface{}, b interface{}) (bool, bool) {
+	cmp, comparable := compareValues(a, b)
+	return comparable && cmp == 0, comparable
+}
+
+// orders two values, the second return is false if they can't be compared: either is NULL or a string compared with a number, a timestamp or a bool doesn't parse as one
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	a, b = parseLike(a, b), parseLike(b, a)
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	switch va := a.(type) {
+	case time.Time:
+		if vb, isTime := b.(time.Time); isTime {
+			return va.Compare(vb), true
+		}
+	case bool:
+		if vb, isBool := b.(bool); isBool {
+			return compareOrdered(boolToInt(va), boolToInt(vb)), true
+		}
+	}
+
+	na, aIsNumber := toNumber(a)
+	nb, bIsNumber := toNumber(b)
+	if aIsNumber && bIsNumber {
+		return na.compare(nb), true
+	}
+
+	sa, aIsString := a.(string)
+	sb, bIsString := b.(string)
+	if aIsString && bIsString {
+		return strings.Compare(sa, sb), true
+	}
+	if a == b {
+		return 0, true
+	}
+	return 0, false
+}
+
+// a string compared with a timestamp or a bool is parsed like a CSV cell of that type, NULL if it doesn't parse
+func parseLike(v interface{}, other interface{}) interface{} {
+	s, isString := v.(string)
+	if !isString {
+		return v
+	}
+	ct := TYPESTRING
+	switch other.(type) {
+	case time.Time:
+		ct = TYPETIMESTAMP
+	case bool:
+		ct = TYPEBOOL
+	}
+	parsed, err := parseValue(s, ct)
+	if err != nil {
+		return nil
+	}
+	return parsed
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// an integer, or a float if isFloat
+type number struct {
+	i       int64
+	f       float64
+	isFloat bool
+}
+
+// strings are integers if they parse as one and floats otherwise
+func toNumber(v interface{}) (number, bool) {
+	switch n := v.(type) {
+	case int:
+		return number{i: int64(n)}, true
+	case int32:
+		return number{i: int64(n)}, true
+	case int64:
+		return number{i: n}, true
+	case float64:
+		return number{f: n, isFloat: true}, true
+	case string:
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return number{i: i}, true
+		}
+		if f, err := strconv.ParseFloat(n, 64); err == nil {
+			return number{f: f, isFloat: true}, true
+		}
+	}
+	return number{}, false
+}
+
+// integers compare as integers, even beyond the 2^53 a float holds exactly - an integer is only widened to a float against a fractional float
+func (n number) compare(o number) int {
+	switch {
+	case !n.isFloat && !o.isFloat:
+		return compareOrdered(n.i, o.i)
+	case n.isFloat && o.isFloat:
+		return compareOrdered(n.f, o.f)
+	case n.isFloat:
+		return -o.compare(n)
+	}
+
+	switch {
+	case o.f >= math.MaxInt64: // 2^63, beyond every int64
+		return -1
+	case o.f < math.MinInt64:
+		return 1
+	case o.f == math.Trunc(o.f):
+		return compareOrdered(n.i, int64(o.f))
+	}
+	return compareOrdered(float64(n.i), o.f) // fractional floats are below 2^53, where rounding the integer can't change the order
+}
+
+// integral floats are keyed like the integers they equal, so the key of a number is the same whichever type holds it
+func (n number) key() string {
+	if n.isFloat && n.f == math.Trunc(n.f) && n.f >= math.MinInt64 && n.f < math.MaxInt64 {
+		return strconv.FormatInt(int64(n.f), 10)
+	}
+	if n.isFloat {
+		return strconv.FormatFloat(n.f, 'g', -1, 64)
+	}
+	return strconv.FormatInt(n.i, 10)
+}
+
+func compareOrdered[T int64 | float64](a T, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
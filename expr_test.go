@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareValues(t *testing.T) {
+	tc := []struct {
+		name       string
+		a, b       interface{}
+		cmp        int
+		comparable bool
+	}{
+		{name: "integers beyond 2^53", a: int64(9007199254740993), b: int64(9007199254740992), cmp: 1, comparable: true},
+		{name: "integer beyond 2^53 and a float", a: int64(9007199254740993), b: 9007199254740992.0, cmp: 1, comparable: true},
+		{name: "integer and an integral float", a: int32(4), b: 4.0, cmp: 0, comparable: true},
+		{name: "integer and a fractional float", a: int64(4), b: 4.5, cmp: -1, comparable: true},
+		{name: "integer and a float beyond int64", a: int64(9223372036854775807), b: 1e19, cmp: -1, comparable: true},
+		{name: "integer string beyond 2^53", a: "9007199254740993", b: int64(9007199254740992), cmp: 1, comparable: true},
+		{name: "numeric strings", a: "4", b: "4.0", cmp: 0, comparable: true},
+		{name: "numeric strings order numerically", a: "10", b: "9", cmp: 1, comparable: true},
+		{name: "strings", a: "Heat", b: "Sholay", cmp: -1, comparable: true},
+		{name: "numeric and other string", a: "10", b: "9a", cmp: -1, comparable: true},
+		{name: "number and a non-numeric string", a: int64(4), b: "four", comparable: false},
+		{name: "timestamp and a date", a: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), b: "2019-03-01", cmp: 0, comparable: true},
+		{name: "timestamp and a later timestamp", a: time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC), b: "2019-03-01T11:00:00+02:00", cmp: 1, comparable: true},
+		{name: "timestamps", a: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), b: time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC), cmp: -1, comparable: true},
+		{name: "timestamp and an unparsable string", a: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), b: "yesterday", comparable: false},
+		{name: "bool and its text", a: true, b: "True", cmp: 0, comparable: true},
+		{name: "bool and a number string", a: false, b: "1", cmp: -1, comparable: true},
+		{name: "bools", a: true, b: false, cmp: 1, comparable: true},
+		{name: "bool and an unparsable string", a: true, b: "yes", comparable: false},
+		{name: "bool and a number", a: true, b: int64(1), comparable: false},
+		{name: "NULL", a: nil, b: nil, comparable: false},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cmp, comparable := compareValues(test.a, test.b)
+			require.Equal(t, test.comparable, comparable)
+			require.Equal(t, test.cmp, cmp)
+			eq, comparable := valuesEqual(test.a, test.b)
+			require.Equal(t, test.comparable, comparable)
+			require.Equal(t, test.comparable && test.cmp == 0, eq)
+			if eq { // equal values must hash alike
+				require.Equal(t, joinKeyValue(test.a), joinKeyValue(test.b))
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chettriyuvraj/query-executor/ycfile"
+	"github.com/stretchr/testify/require"
+)
+
+// writes ratings with binary typed fields, the rating of user i is i%5 + 0.5 and movieId is i%4
+func writeTypedRatingsYCFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "ratings.ycf")
+	require.NoError(t, ycfile.CreateYCFile(path, []string{"userId", "movieId", "rating", "timestamp", "title"}, []byte{ycfile.INT64, ycfile.INT32, ycfile.FLOAT64, ycfile.TIMESTAMP, ycfile.STRINGVAR}))
+
+	writer, err := ycfile.NewYCFileWriter(path)
+	require.NoError(t, err)
+	defer writer.Close()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, writer.Write(ycfile.YCFileRecord{Data: []ycfile.FieldPair{
+			{Key: "userId", Val: int64(i)},
+			{Key: "movieId", Val: int32(i % 4)},
+			{Key: "rating", Val: float64(i%5) + 0.5},
+			{Key: "timestamp", Val: time.Unix(int64(i)*86400, 0).UTC()},
+			{Key: "title", Val: "American President, The (1995)\nwith a newline"},
+		}}))
+	}
+	return path
+}
+
+func TestTypedFileScan(t *testing.T) {
+	path := writeTypedRatingsYCFile(t)
+
+	tc := []struct {
+		name     string
+		plan     PlanNode
+		expected []Tuple
+	}{
+		{
+			name:     "typed values",
+			plan:     &LimitNode{limit: 1, inputs: []PlanNode{&FileScanNode{path: path}}},
+			expected: []Tuple{{data: map[string]interface{}{"userId": int64(0), "movieId": int32(0), "rating": 0.5, "timestamp": time.Unix(0, 0).UTC(), "title": "American President, The (1995)\nwith a newline"}}},
+		},
+		{
+			name:     "average of a pushed down int32 filter",
+			plan:     &AvgNode{header: "rating", inputs: []PlanNode{&FilterNode{header: "movieId", operator: "=", cmpValue: "2", inputs: []PlanNode{&FileScanNode{path: path}}}}},
+			expected: []Tuple{{data: map[string]interface{}{"average": (2.5 + 1.5 + 0.5 + 4.5 + 3.5) / 5}}},
+		},
+		{
+			name:     "float filter compares as a number",
+			plan:     &ProjectionNode{reqHeaders: []string{"userId"}, inputs: []PlanNode{&FilterNode{header: "rating", operator: "=", cmpValue: "4.50", inputs: []PlanNode{&FileScanNode{path: path}}}}},
+			expected: []Tuple{{data: map[string]interface{}{"userId": int64(4)}}, {data: map[string]interface{}{"userId": int64(9)}}, {data: map[string]interface{}{"userId": int64(14)}}, {data: map[string]interface{}{"userId": int64(19)}}},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.plan})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+		})
+	}
+}
+
+func TestNullableFileScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratings.ycf")
+	require.NoError(t, ycfile.CreateYCFile(path, []string{"userId", "rating", "title"}, []byte{ycfile.INT64, ycfile.FLOAT64 | ycfile.NULLABLE, ycfile.STRINGVAR | ycfile.NULLABLE}))
+	writer, err := ycfile.NewYCFileWriter(path)
+	require.NoError(t, err)
+	for i := 0; i < 6; i++ {
+		var rating, title interface{} = float64(i), "Heat"
+		if i%2 == 1 {
+			rating, title = nil, nil
+		}
+		require.NoError(t, writer.Write(ycfile.YCFileRecord{Data: []ycfile.FieldPair{{Key: "userId", Val: int64(i)}, {Key: "rating", Val: rating}, {Key: "title", Val: title}}}))
+	}
+	require.NoError(t, writer.Close())
+
+	tc := []struct {
+		name     string
+		plan     PlanNode
+		expected []Tuple
+	}{
+		{
+			name:     "NULLs are nil",
+			plan:     &LimitNode{limit: 2, inputs: []PlanNode{&FileScanNode{path: path}}},
+			expected: []Tuple{{data: map[string]interface{}{"userId": int64(0), "rating": 0.0, "title": "Heat"}}, {data: map[string]interface{}{"userId": int64(1), "rating": nil, "title": nil}}},
+		},
+		{
+			name:     "average skips NULLs",
+			plan:     &AvgNode{header: "rating", inputs: []PlanNode{&FileScanNode{path: path}}},
+			expected: []Tuple{{data: map[string]interface{}{"average": (0.0 + 2 + 4) / 3}}},
+		},
+		{
+			name:     "pushed down filters don't match NULLs",
+			plan:     &ProjectionNode{reqHeaders: []string{"userId"}, inputs: []PlanNode{&FilterNode{header: "title", operator: "=", cmpValue: "Heat", inputs: []PlanNode{&FileScanNode{path: path}}}}},
+			expected: []Tuple{{data: map[string]interface{}{"userId": int64(0)}}, {data: map[string]interface{}{"userId": int64(2)}}, {data: map[string]interface{}{"userId": int64(4)}}},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.plan})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+		})
+	}
+}
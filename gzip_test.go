@@ -0,0 +1,84 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func gzipFile(t *testing.T, path string, gzPath string) string {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	f, err := os.Create(gzPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w := gzip.NewWriter(f)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return gzPath
+}
+
+func TestGzipSources(t *testing.T) {
+	_, ratings := syntheticMovieRatings(40, 300, 60)
+	dir := t.TempDir()
+	csvPath, ycfPath := writeRatingsCSV(t, ratings), writeRatingsYCFile(t, ratings)
+	jsonlPath := filepath.Join(dir, "ratings.jsonl")
+	jsonl := ""
+	for _, r := range ratings.data {
+		jsonl += `{"userId": "` + r["userId"].(string) + `", "movieId": "` + r["movieId"].(string) + `"}` + "\n"
+	}
+	require.NoError(t, os.WriteFile(jsonlPath, []byte(jsonl), 0666))
+
+	tc := []struct {
+		name  string
+		plain PlanNode
+		gz    PlanNode
+	}{
+		{name: "csv", plain: &CSVScanNode{path: csvPath}, gz: &CSVScanNode{path: gzipFile(t, csvPath, filepath.Join(dir, "ratings.csv.gz"))}},
+		{name: "csv detected by magic bytes", plain: &CSVScanNode{path: csvPath}, gz: &CSVScanNode{path: gzipFile(t, csvPath, filepath.Join(dir, "ratings-compressed.csv"))}},
+		{name: "json lines", plain: &JSONLScanNode{path: jsonlPath}, gz: &JSONLScanNode{path: gzipFile(t, jsonlPath, filepath.Join(dir, "ratings.jsonl.gz"))}},
+		{name: "ycfile", plain: &FileScanNode{path: ycfPath}, gz: &FileScanNode{path: gzipFile(t, ycfPath, filepath.Join(dir, "ratings.ycf.gz"))}},
+		{name: "ycfile range", plain: &FileScanNode{path: ycfPath, partition: 2, partitionCount: 3}, gz: &FileScanNode{path: filepath.Join(dir, "ratings.ycf.gz"), partition: 2, partitionCount: 3}},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			qe := QueryExecutor{}
+			expected, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.plain})
+			require.NoError(t, err)
+			require.NotEmpty(t, expected)
+
+			/* read everything, then reset halfway through a second pass and read everything again */
+			require.NoError(t, InitPlanNode(test.gz))
+			defer ClosePlanNode(test.gz)
+			for _, stopAt := range []int{-1, len(expected) / 2, -1} {
+				res := []Tuple{}
+				for len(res) != stopAt {
+					tuple, err := test.gz.next()
+					require.NoError(t, err)
+					if tuple.data == nil {
+						break
+					}
+					res = append(res, tuple)
+				}
+				if stopAt == -1 {
+					require.Equal(t, expected, res)
+				}
+				require.NoError(t, test.gz.reset())
+			}
+		})
+	}
+
+	t.Run("gz extension without gzip content", func(t *testing.T) {
+		path := filepath.Join(dir, "plain.csv.gz")
+		require.NoError(t, os.WriteFile(path, []byte("userId,movieId\n1,2\n"), 0666))
+		qe := QueryExecutor{}
+		_, err := qe.ExecutePlan(&QueryDescriptor{planNode: &CSVScanNode{path: path}})
+		require.ErrorIs(t, err, gzip.ErrHeader)
+	})
+}
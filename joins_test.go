@@ -1,8 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/chettriyuvraj/query-executor/ycfile"
 	"github.com/stretchr/testify/require"
 )
 
@@ -50,3 +56,435 @@ func TestJoin(t *testing.T) {
 		require.Equal(t, res[0].data["average"], 3.921239561324077)
 	}
 }
+
+func TestHashJoinDuplicateKeys(t *testing.T) {
+	movies := Table{
+		headers: []string{"movieId", "title"},
+		data: []map[string]interface{}{
+			{"movieId": "1", "title": "Toy Story"},
+			{"movieId": "1", "title": "Toy Story (Director's Cut)"},
+			{"movieId": "2", "title": "Jumanji"},
+			{"movieId": "3", "title": "Heat"},
+		},
+	}
+	ratings := Table{
+		headers: []string{"userId", "movieId", "rating"},
+		data: []map[string]interface{}{
+			{"userId": "10", "movieId": "1", "rating": "4.0"},
+			{"userId": "11", "movieId": "1", "rating": "3.5"},
+			{"userId": "12", "movieId": "1", "rating": "5.0"},
+			{"userId": "10", "movieId": "2", "rating": "2.0"},
+			{"userId": "13", "movieId": "2", "rating": "3.0"},
+			{"userId": "14", "movieId": "4", "rating": "1.0"},
+		},
+	}
+
+	hashJoinNode := &HashJoinNode{
+		reqHeaders:     []string{"movieId", "movieId"},
+		partitionCount: 4,
+		headersInOrder: [][]string{movies.headers, ratings.headers},
+		inputs:         []PlanNode{&TableScanNode{table: movies}, &TableScanNode{table: ratings}},
+	}
+	qd := QueryDescriptor{cmd: COMMANDS["SELECT"], text: "SELECT * FROM movies m, ratings r WHERE m.movieId = r.movieId;", planNode: hashJoinNode}
+	qe := QueryExecutor{}
+	res, err := qe.ExecutePlan(&qd)
+	require.NoError(t, err)
+
+	/* every r row must meet every s row carrying the same key: 2*3 for movieId 1, 1*2 for movieId 2 */
+	matches := map[string]int{}
+	for _, tuple := range res {
+		matches[fmt.Sprintf("%v|%v|%v", tuple.data["movieId"], tuple.data["title"], tuple.data["userId"])]++
+	}
+	require.Len(t, res, 8)
+	require.Equal(t, map[string]int{
+		"1|Toy Story|10": 1, "1|Toy Story|11": 1, "1|Toy Story|12": 1,
+		"1|Toy Story (Director's Cut)|10": 1, "1|Toy Story (Director's Cut)|11": 1, "1|Toy Story (Director's Cut)|12": 1,
+		"2|Jumanji|10": 1, "2|Jumanji|13": 1,
+	}, matches)
+}
+
+func TestHashJoinSkew(t *testing.T) {
+	/* movieId 1 is a blockbuster whose r partition can never fit in a single page, the remaining movies only fit once repartitioned */
+	movies := Table{headers: []string{"movieId", "title"}}
+	for i := 0; i < 300; i++ {
+		movies.data = append(movies.data, map[string]interface{}{"movieId": "1", "title": fmt.Sprintf("t%d", i)})
+	}
+	for i := 2; i < 400; i++ {
+		movies.data = append(movies.data, map[string]interface{}{"movieId": strconv.Itoa(i), "title": fmt.Sprintf("t%d", i)})
+	}
+	_, ratings := syntheticMovieRatings(0, 1000, 10)
+	expectedMatches := 0
+	for _, r := range movies.data {
+		for _, s := range ratings.data {
+			if r["movieId"] == s["movieId"] {
+				expectedMatches++
+			}
+		}
+	}
+
+	hashJoinNode := &HashJoinNode{
+		reqHeaders:     []string{"movieId", "movieId"},
+		partitionCount: 2,
+		memoryPages:    1,
+		headersInOrder: [][]string{movies.headers, ratings.headers},
+		inputs:         []PlanNode{&TableScanNode{table: movies}, &TableScanNode{table: ratings}},
+	}
+	qd := QueryDescriptor{cmd: COMMANDS["SELECT"], text: "SELECT * FROM movies m, ratings r WHERE m.movieId = r.movieId;", planNode: hashJoinNode}
+	qe := QueryExecutor{}
+	res, err := qe.ExecutePlan(&qd)
+	require.NoError(t, err)
+
+	require.Len(t, res, expectedMatches)
+	require.Greater(t, hashJoinNode.stats.repartitions, 0)
+	require.Greater(t, hashJoinNode.stats.blockNestedLoops, 0)
+}
+
+func TestHybridHashJoin(t *testing.T) {
+	movies, ratings := syntheticMovieRatings(1000, 2000, 250)
+
+	tc := []struct {
+		name         string
+		memoryPages  int
+		residentFits bool
+	}{
+		{name: "resident partition fits", memoryPages: 10, residentFits: true},
+		{name: "resident partition spills", memoryPages: 1, residentFits: false},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			run := func(hybrid bool) ([]Tuple, *HashJoinNode) {
+				hashJoinNode := &HashJoinNode{
+					reqHeaders:     []string{"movieId", "movieId"},
+					partitionCount: 4,
+					memoryPages:    test.memoryPages,
+					hybrid:         hybrid,
+					headersInOrder: [][]string{movies.headers, ratings.headers},
+					inputs:         []PlanNode{&TableScanNode{table: movies}, &TableScanNode{table: ratings}},
+				}
+				qe := QueryExecutor{}
+				res, err := qe.ExecutePlan(&QueryDescriptor{cmd: COMMANDS["SELECT"], planNode: hashJoinNode})
+				require.NoError(t, err)
+				return res, hashJoinNode
+			}
+
+			graceRes, grace := run(false)
+			hybridRes, hybrid := run(true)
+
+			require.Len(t, graceRes, 2000)
+			require.ElementsMatch(t, graceRes, hybridRes)
+			require.Equal(t, test.residentFits, hybrid.stats.residentProbes > 0)
+			if test.residentFits { // nothing is repartitioned, so grace spills every tuple exactly once
+				require.Equal(t, len(movies.data)+len(ratings.data), grace.stats.tuplesSpilled)
+				require.Less(t, hybrid.stats.tuplesSpilled, grace.stats.tuplesSpilled)
+			}
+		})
+	}
+}
+
+// countingNode counts the tuples pulled from its input
+type countingNode struct {
+	pulls  int
+	inputs []PlanNode
+}
+
+func (cn *countingNode) init() error                    { return nil }
+func (cn *countingNode) close() error                   { return nil }
+func (cn *countingNode) reset() error                   { return resetPlanNode(cn) }
+func (cn *countingNode) getInputs() ([]PlanNode, error) { return cn.inputs, nil }
+func (cn *countingNode) setInputs(inps []PlanNode)      { cn.inputs = inps }
+func (cn *countingNode) next() (Tuple, error) {
+	cn.pulls++
+	return cn.inputs[0].next()
+}
+
+func TestStreamingJoinLimit(t *testing.T) {
+	movies, ratings := syntheticMovieRatings(100, 1000, 100)
+
+	tc := []struct {
+		name     string
+		joinNode PlanNode
+	}{
+		{name: "naive nested loop", joinNode: &NaiveNestedJoinNode{headers: []string{"movieId", "movieId"}}},
+		{name: "chunk nested loop", joinNode: &ChunkNestedJoinNode{headers: []string{"movieId", "movieId"}, numberOfPages: 1}},
+		{name: "hybrid hash join", joinNode: &HashJoinNode{reqHeaders: []string{"movieId", "movieId"}, partitionCount: 4, hybrid: true, headersInOrder: [][]string{movies.headers, ratings.headers}}},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			outer, inner := &countingNode{inputs: []PlanNode{&TableScanNode{table: movies}}}, &countingNode{inputs: []PlanNode{&TableScanNode{table: ratings}}}
+			test.joinNode.setInputs([]PlanNode{outer, inner})
+			spillDir := t.TempDir()
+			qd := QueryDescriptor{cmd: COMMANDS["SELECT"], text: "SELECT * FROM movies m, ratings r WHERE m.movieId = r.movieId LIMIT 3;", planNode: &LimitNode{limit: 3, inputs: []PlanNode{test.joinNode}}, qctx: &QueryContext{spill: NewSpillManager(spillDir, 0)}}
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&qd)
+			require.NoError(t, err)
+
+			require.Len(t, res, 3)
+			for _, tuple := range res {
+				require.NotNil(t, tuple.data["title"])
+				require.NotNil(t, tuple.data["userId"])
+			}
+			require.Less(t, inner.pulls, len(ratings.data), "join must stop pulling once the limit is reached")
+			entries, err := os.ReadDir(spillDir)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+		})
+	}
+}
+
+func TestJoinPredicates(t *testing.T) {
+	/* periods of a day, ratings fall into the period containing their timestamp */
+	periods := Table{headers: []string{"period", "start", "end"}}
+	for i := 0; i < 10; i++ {
+		periods.data = append(periods.data, map[string]interface{}{"period": strconv.Itoa(i), "start": strconv.Itoa(i * 100), "end": strconv.Itoa(i*100 + 99)})
+	}
+	ratings := Table{headers: []string{"userId", "movieId", "timestamp"}}
+	for i := 0; i < 300; i++ {
+		ratings.data = append(ratings.data, map[string]interface{}{"userId": strconv.Itoa(i % 7), "movieId": strconv.Itoa(i % 5), "timestamp": strconv.Itoa(i * 3)})
+	}
+	tags := Table{headers: []string{"taggedUserId", "taggedMovieId", "taggedAt"}}
+	for i := 0; i < 50; i++ {
+		tags.data = append(tags.data, map[string]interface{}{"taggedUserId": strconv.Itoa(i % 7), "taggedMovieId": strconv.Itoa(i % 5), "taggedAt": strconv.Itoa(i * 15)})
+	}
+
+	rangePredicate := &BetweenExpr{value: &ColumnExpr{header: "timestamp"}, low: &ColumnExpr{header: "start"}, high: &ColumnExpr{header: "end"}}
+	multiColumnPredicate := &AndExpr{exprs: []Expr{
+		&CmpExpr{operator: "=", left: &ColumnExpr{header: "movieId"}, right: &ColumnExpr{header: "taggedMovieId"}},
+		&CmpExpr{operator: "=", left: &ColumnExpr{header: "taggedUserId"}, right: &ColumnExpr{header: "userId"}},
+		&CmpExpr{operator: "<", left: &ColumnExpr{header: "timestamp"}, right: &ColumnExpr{header: "taggedAt"}},
+	}}
+
+	bruteForce := func(r Table, s Table, pred Expr) int {
+		count := 0
+		for _, t1 := range r.data {
+			for _, t2 := range s.data {
+				ok, err := evalPredicate(pred, joinedRow{left: Tuple{data: t1}, right: Tuple{data: t2}})
+				require.NoError(t, err)
+				if ok {
+					count++
+				}
+			}
+		}
+		return count
+	}
+
+	tc := []struct {
+		name     string
+		r        Table
+		s        Table
+		joinNode PlanNode
+		expected int
+	}{
+		{name: "range chunk nested loop", r: periods, s: ratings, joinNode: &ChunkNestedJoinNode{predicate: rangePredicate, numberOfPages: 1}, expected: 300},
+		{name: "multi-column chunk nested loop", r: tags, s: ratings, joinNode: &ChunkNestedJoinNode{predicate: multiColumnPredicate, numberOfPages: 1}, expected: bruteForce(tags, ratings, multiColumnPredicate)},
+		{name: "multi-column hash join", r: tags, s: ratings, joinNode: &HashJoinNode{predicate: multiColumnPredicate, partitionCount: 4, headersInOrder: [][]string{tags.headers, ratings.headers}}, expected: bruteForce(tags, ratings, multiColumnPredicate)},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.joinNode.setInputs([]PlanNode{&TableScanNode{table: test.r}, &TableScanNode{table: test.s}})
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{cmd: COMMANDS["SELECT"], planNode: test.joinNode})
+			require.NoError(t, err)
+			require.Len(t, res, test.expected)
+			require.Greater(t, test.expected, 0)
+		})
+	}
+}
+
+func TestHashJoinBloomFilter(t *testing.T) {
+	/* only a handful of movies survive the build side, most ratings can't match */
+	movies, ratings := syntheticMovieRatings(10, 2000, 200)
+	ratings.headers = append(ratings.headers, "rating")
+	for _, r := range ratings.data {
+		r["rating"] = "4.0"
+	}
+
+	csvPath := writeRatingsCSV(t, ratings)
+
+	tc := []struct {
+		name       string
+		probeInput func() PlanNode
+	}{
+		{name: "table scan checked by the join", probeInput: func() PlanNode { return &TableScanNode{table: ratings} }},
+		{name: "filter node", probeInput: func() PlanNode {
+			return &FilterNode{header: "rating", operator: "=", cmpValue: "4.0", inputs: []PlanNode{&TableScanNode{table: ratings}}}
+		}},
+		{name: "csv scan", probeInput: func() PlanNode { return &CSVScanNode{path: csvPath} }},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			run := func(bloomFilter bool) ([]Tuple, *HashJoinNode) {
+				hashJoinNode := &HashJoinNode{
+					reqHeaders:     []string{"movieId", "movieId"},
+					partitionCount: 4,
+					bloomFilter:    bloomFilter,
+					headersInOrder: [][]string{movies.headers, ratings.headers},
+					inputs:         []PlanNode{&TableScanNode{table: movies}, test.probeInput()},
+				}
+				qe := QueryExecutor{}
+				res, err := qe.ExecutePlan(&QueryDescriptor{cmd: COMMANDS["SELECT"], planNode: hashJoinNode})
+				require.NoError(t, err)
+				return res, hashJoinNode
+			}
+
+			plainRes, plain := run(false)
+			bloomRes, bloom := run(true)
+
+			require.Len(t, plainRes, 100)
+			require.ElementsMatch(t, plainRes, bloomRes)
+			require.Greater(t, bloom.stats.probeRowsSkipped, 1800)
+			require.Equal(t, len(movies.data)+len(ratings.data)-bloom.stats.probeRowsSkipped, bloom.stats.tuplesSpilled)
+			require.Less(t, bloom.stats.tuplesSpilled, plain.stats.tuplesSpilled)
+
+			// the plan is left as it was built, and a rerun filters on the keys again
+			probeInput := bloom.inputs[1]
+			require.NoError(t, bloom.reset())
+			require.Same(t, probeInput, bloom.inputs[1])
+			require.IsType(t, test.probeInput(), bloom.inputs[1])
+			rerunRes := []Tuple{}
+			for {
+				tuple, err := bloom.next()
+				require.NoError(t, err)
+				if tuple.data == nil {
+					break
+				}
+				rerunRes = append(rerunRes, tuple)
+			}
+			require.NoError(t, ClosePlanNode(bloom))
+			require.ElementsMatch(t, plainRes, rerunRes)
+			require.Greater(t, bloom.stats.probeRowsSkipped, 1800)
+		})
+	}
+}
+
+func TestJoinMemoryBudget(t *testing.T) {
+	movies, ratings := syntheticMovieRatings(500, 1000, 50)
+	headersInOrder := [][]string{movies.headers, ratings.headers}
+
+	tc := []struct {
+		name        string
+		budget      int64
+		joinNode    PlanNode
+		expectedErr error
+	}{
+		{name: "hash join within budget", budget: 1 << 20, joinNode: &HashJoinNode{reqHeaders: []string{"movieId", "movieId"}, partitionCount: 4, hybrid: true, headersInOrder: headersInOrder}},
+		{name: "hash join spills when the budget runs out", budget: 8 * 1024, joinNode: &HashJoinNode{reqHeaders: []string{"movieId", "movieId"}, partitionCount: 4, hybrid: true, headersInOrder: headersInOrder}},
+		{name: "chunk join shrinks chunks to the budget", budget: 8 * 1024, joinNode: &ChunkNestedJoinNode{headers: []string{"movieId", "movieId"}, numberOfPages: 100}},
+		{name: "chunk join can't hold a single tuple", budget: 16, joinNode: &ChunkNestedJoinNode{headers: []string{"movieId", "movieId"}, numberOfPages: 100}, expectedErr: errOutOfMemoryBudget},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.joinNode.setInputs([]PlanNode{&TableScanNode{table: movies}, &CSVScanNode{path: writeRatingsCSV(t, ratings)}})
+			budget := NewMemoryBudget(test.budget)
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{cmd: COMMANDS["SELECT"], planNode: test.joinNode, qctx: &QueryContext{memory: budget}})
+
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, res, 1000)
+				require.LessOrEqual(t, budget.peak, test.budget)
+			}
+			require.Zero(t, budget.inUse())
+		})
+	}
+}
+
+func TestSizeOfTuple(t *testing.T) {
+	short := Tuple{data: map[string]interface{}{"title": "Heat"}}
+	long := Tuple{data: map[string]interface{}{"title": "American President, The (1995)"}}
+	number := Tuple{data: map[string]interface{}{"title": int64(1995)}}
+
+	require.Equal(t, sizeOfTuple(short)+len("American President, The (1995)")-len("Heat"), sizeOfTuple(long))
+	require.Less(t, sizeOfTuple(number), sizeOfTuple(short))
+}
+
+func writeRatingsCSV(t *testing.T, ratings Table) string {
+	path := filepath.Join(t.TempDir(), "ratings.csv")
+	content := strings.Join(ratings.headers, ",") + "\n"
+	for _, r := range ratings.data {
+		values := []string{}
+		for _, header := range ratings.headers {
+			values = append(values, fmt.Sprint(r[header]))
+		}
+		content += strings.Join(values, ",") + "\n"
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0666))
+	return path
+}
+
+func writeRatingsYCFile(t *testing.T, ratings Table) string {
+	path := filepath.Join(t.TempDir(), "ratings.ycf")
+	fieldTypes := make([]byte, len(ratings.headers))
+	for i := range fieldTypes {
+		fieldTypes[i] = ycfile.STRINGSMALL
+	}
+	require.NoError(t, ycfile.CreateYCFile(path, ratings.headers, fieldTypes))
+
+	writer, err := ycfile.NewYCFileWriter(path)
+	require.NoError(t, err)
+	for _, r := range ratings.data {
+		record := ycfile.YCFileRecord{}
+		for _, header := range ratings.headers {
+			record.Data = append(record.Data, ycfile.StringPair{Key: header, Val: fmt.Sprint(r[header])})
+		}
+		require.NoError(t, writer.Write(record))
+	}
+	require.NoError(t, writer.Close())
+	return path
+}
+
+func TestTypedJoinKeys(t *testing.T) {
+	movies := Table{headers: []string{"movieId", "title"}, data: []map[string]interface{}{
+		{"movieId": "4.0", "title": "Heat"},
+		{"movieId": "04", "title": "Sholay"},
+		{"movieId": "5", "title": "Chole"},
+		{"movieId": "x", "title": "Untitled"},
+		{"movieId": "-0", "title": "Zero"},
+	}}
+	ratings := Table{headers: []string{"userId", "ratedMovieId"}, data: []map[string]interface{}{
+		{"userId": "1", "ratedMovieId": "4"},
+		{"userId": "2", "ratedMovieId": "5"},
+		{"userId": "3", "ratedMovieId": "0"},
+		{"userId": "4", "ratedMovieId": "6"},
+	}}
+	path := writeRatingsCSV(t, ratings)
+	headers := []string{"movieId", "ratedMovieId"}
+
+	tc := []struct {
+		name     string
+		schema   map[string]ColumnType
+		expected int
+	}{
+		{name: "typed ratings match numerically", schema: map[string]ColumnType{"ratedMovieId": TYPEINT}, expected: 4},
+		{name: "untyped ratings match numeric strings numerically", expected: 4},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			joins := map[string]PlanNode{
+				"hash":              &HashJoinNode{reqHeaders: headers, partitionCount: 2, headersInOrder: [][]string{movies.headers, ratings.headers}},
+				"hybrid hash":       &HashJoinNode{reqHeaders: headers, partitionCount: 2, hybrid: true, bloomFilter: true, headersInOrder: [][]string{movies.headers, ratings.headers}},
+				"naive nested loop": &NaiveNestedJoinNode{headers: headers},
+				"chunk nested loop": &ChunkNestedJoinNode{headers: headers, numberOfPages: 1},
+			}
+			results := map[string][]Tuple{}
+			for name, join := range joins {
+				join.setInputs([]PlanNode{&TableScanNode{table: movies}, &CSVScanNode{path: path, schema: test.schema}})
+				qe := QueryExecutor{}
+				res, err := qe.ExecutePlan(&QueryDescriptor{cmd: COMMANDS["SELECT"], planNode: join})
+				require.NoError(t, err)
+				require.Len(t, res, test.expected, name)
+				results[name] = res
+			}
+			for name, res := range results {
+				require.ElementsMatch(t, results["naive nested loop"], res, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+/*** JSON Lines Scan Node - one JSON object per line, blank lines are skipped
+Numbers become int64 or float64, nested objects become dotted columns (user.id) when flattening and compact JSON text otherwise, arrays are always kept as JSON text
+With a schema, tuples have exactly the declared columns (missing keys are NULL) and values are converted to the declared types ***/
+
+type JSONLScanNode struct {
+	idx          int
+	file         *sourceFile // gzipped files are decompressed on the fly
+	reader       *bufio.Reader
+	line         int             // lines read so far
+	path         string          // a file, a directory or a glob pattern - several files are read in lexical order as one table
+	files        multiFileSource // files path resolves to
+	sourceColumn string          // if set, every tuple carries the path of its file under this header
+	filters      []scanFilter    // pushed down by the optimizer, partitions they reject are never read
+	flatten      bool
+	schema       map[string]ColumnType // declared columns, every key of every object is a column if unset
+	columns      []string              // required columns, every column if nil
+	malformed    int                   // MALFORMEDERROR, MALFORMEDNULL or MALFORMEDSKIP for values that don't convert to their declared type
+	bloom        *bloomProbe           // pushed down from a hash join, nil if none
+	inputs       []PlanNode
+}
+
+func (jsn *JSONLScanNode) init() error {
+	err := jsn.files.resolve(jsn.path, jsn.sourceColumn, jsn.filters, jsn.columns)
+	if err != nil {
+		return err
+	}
+	declared := []string{}
+	for header := range jsn.schema {
+		declared = append(declared, header)
+	}
+	if err := jsn.files.checkColumns(declared, "declared column"); err != nil {
+		return err
+	}
+	if jsn.files.empty() { // every partition was pruned
+		return nil
+	}
+
+	return jsn.openFile()
+}
+
+func (jsn *JSONLScanNode) openFile() error {
+	file, err := openSource(jsn.files.current())
+	if err != nil {
+		return err
+	}
+	jsn.file, jsn.reader, jsn.line = file, bufio.NewReader(file), 0
+
+	return nil
+}
+
+func (jsn *JSONLScanNode) next() (Tuple, error) {
+	if jsn.files.empty() {
+		return Tuple{}, nil // EOF
+	}
+
+	for {
+		line, err := jsn.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return Tuple{}, err
+		}
+		if len(line) == 0 && err == io.EOF {
+			more, err := jsn.files.nextFile(jsn.close, jsn.openFile)
+			if err != nil {
+				return Tuple{}, err
+			}
+			if !more {
+				return Tuple{}, nil // EOF
+			}
+			continue
+		}
+		jsn.line++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		tuple, keep, parseErr := jsn.lineToTuple(line)
+		if parseErr != nil {
+			return Tuple{}, fmt.Errorf("%v: line %d: %w", jsn.files.current(), jsn.line, parseErr)
+		}
+		if keep {
+			jsn.files.addValues(tuple)
+		}
+		jsn.idx++
+
+		if !keep || (jsn.bloom != nil && !jsn.bloom.mayMatch(tuple)) {
+			continue
+		}
+
+		return tuple, nil
+	}
+}
+
+// decodes one object, keep is false if the row is to be skipped for a malformed value
+func (jsn *JSONLScanNode) lineToTuple(line []byte) (Tuple, bool, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return Tuple{}, false, err
+	}
+	if object == nil {
+		return Tuple{}, false, fmt.Errorf("expected an object, got null")
+	}
+	if decoder.More() {
+		return Tuple{}, false, fmt.Errorf("more than one value on the line")
+	}
+
+	data := map[string]interface{}{}
+	if err := jsn.addValues(data, "", object); err != nil {
+		return Tuple{}, false, err
+	}
+	if jsn.schema == nil {
+		for header := range data {
+			if !isRequiredColumn(header, jsn.columns) {
+				delete(data, header)
+			}
+		}
+		return Tuple{data: data}, true, nil
+	}
+
+	tuple := Tuple{data: make(map[string]interface{}, len(jsn.schema))}
+	for header, ct := range jsn.schema {
+		required := isRequiredColumn(header, jsn.columns)
+		if !required && jsn.malformed != MALFORMEDSKIP { // values of other columns still decide which rows are skipped
+			continue
+		}
+		value, err := convertJSONValue(data[header], ct)
+		if err != nil {
+			switch jsn.malformed {
+			case MALFORMEDNULL:
+				value = nil
+			case MALFORMEDSKIP:
+				return Tuple{}, false, nil
+			default:
+				return Tuple{}, false, fmt.Errorf("column %v: %w", header, err)
+			}
+		}
+		if required {
+			tuple.data[header] = value
+		}
+	}
+	return tuple, true, nil
+}
+
+// adds the values of an object to data, nested objects under dotted names when flattening
+func (jsn *JSONLScanNode) addValues(data map[string]interface{}, prefix string, object map[string]interface{}) error {
+	for key, value := range object {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if jsn.flatten {
+				if err := jsn.addValues(data, prefix+key+".", v); err != nil {
+					return err
+				}
+				continue
+			}
+			text, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			data[prefix+key] = string(text)
+		case []interface{}:
+			text, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			data[prefix+key] = string(text)
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				data[prefix+key] = n
+			} else if f, err := v.Float64(); err == nil {
+				data[prefix+key] = f
+			} else {
+				return err
+			}
+		default: // string, bool or nil
+			data[prefix+key] = v
+		}
+	}
+	return nil
+}
+
+// converts a decoded JSON value to a declared column type, strings are parsed like CSV cells and numbers are unix seconds for timestamps
+func convertJSONValue(value interface{}, ct ColumnType) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if ct == TYPESTRING {
+			return v, nil
+		}
+		return parseValue(v, ct)
+	case int64:
+		switch ct {
+		case TYPEINT:
+			return v, nil
+		case TYPEFLOAT:
+			return float64(v), nil
+		case TYPESTRING:
+			return strconv.FormatInt(v, 10), nil
+		case TYPETIMESTAMP:
+			return time.Unix(v, 0).UTC(), nil
+		}
+	case float64:
+		switch ct {
+		case TYPEFLOAT:
+			return v, nil
+		case TYPESTRING:
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+	case bool:
+		switch ct {
+		case TYPEBOOL:
+			return v, nil
+		case TYPESTRING:
+			return strconv.FormatBool(v), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %v to %v", value, ct)
+}
+
+func (jsn *JSONLScanNode) setBloomProbe(bp *bloomProbe) {
+	jsn.bloom = bp
+}
+
+func (jsn *JSONLScanNode) addScanFilter(sf scanFilter) {
+	jsn.filters = addScanFilter(jsn.filters, sf)
+}
+
+func (jsn *JSONLScanNode) setRequiredColumns(columns []string) {
+	jsn.columns = columns
+}
+
+func (jsn *JSONLScanNode) close() error {
+	if jsn.file == nil { // never initialised
+		return nil
+	}
+	err := jsn.file.Close()
+	jsn.file, jsn.reader = nil, nil
+	return err
+}
+
+func (jsn *JSONLScanNode) getInputs() ([]PlanNode, error) {
+	return jsn.inputs, nil
+}
+
+func (jsn *JSONLScanNode) reset() error { // reopens the file
+	err := jsn.close()
+	if err != nil {
+		return err
+	}
+	jsn.idx = 0
+	return jsn.init()
+}
+
+func (jsn *JSONLScanNode) setInputs(inps []PlanNode) {
+	jsn.inputs = inps
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLScanNode(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0666))
+		return path
+	}
+	events := writeFile("events.jsonl", `{"userId": 1, "movieId": "11", "rating": 4.5, "user": {"name": "ann", "address": {"city": "Pune"}}, "tags": ["a", "b"]}`+"\n\n"+
+		`{"userId": 2, "movieId": "12", "rating": 3, "user": null, "watched": true}`+"\r\n")
+	malformed := writeFile("malformed.jsonl", `{"userId": 1, "ratedAt": 1551434400}`+"\n"+`{"userId": "two", "ratedAt": "2019-03-02"}`+"\n"+`{"userId": 3}`)
+
+	tc := []struct {
+		name     string
+		node     *JSONLScanNode
+		expected []Tuple
+		err      string
+	}{
+		{
+			name: "nested values kept as JSON",
+			node: &JSONLScanNode{path: events},
+			expected: []Tuple{
+				{data: map[string]interface{}{"userId": int64(1), "movieId": "11", "rating": 4.5, "user": `{"address":{"city":"Pune"},"name":"ann"}`, "tags": `["a","b"]`}},
+				{data: map[string]interface{}{"userId": int64(2), "movieId": "12", "rating": int64(3), "user": nil, "watched": true}},
+			},
+		},
+		{
+			name: "flattened",
+			node: &JSONLScanNode{path: events, flatten: true},
+			expected: []Tuple{
+				{data: map[string]interface{}{"userId": int64(1), "movieId": "11", "rating": 4.5, "user.name": "ann", "user.address.city": "Pune", "tags": `["a","b"]`}},
+				{data: map[string]interface{}{"userId": int64(2), "movieId": "12", "rating": int64(3), "user": nil, "watched": true}},
+			},
+		},
+		{
+			name: "schema",
+			node: &JSONLScanNode{path: events, flatten: true, schema: map[string]ColumnType{"movieId": TYPEINT, "rating": TYPEFLOAT, "user.name": TYPESTRING, "watched": TYPEBOOL}},
+			expected: []Tuple{
+				{data: map[string]interface{}{"movieId": int64(11), "rating": 4.5, "user.name": "ann", "watched": nil}},
+				{data: map[string]interface{}{"movieId": int64(12), "rating": 3.0, "user.name": nil, "watched": true}},
+			},
+		},
+		{
+			name: "malformed value errors",
+			node: &JSONLScanNode{path: malformed, schema: map[string]ColumnType{"userId": TYPEINT, "ratedAt": TYPETIMESTAMP}},
+			err:  "malformed.jsonl: line 2: column userId: cannot parse \"two\" as int",
+		},
+		{
+			name: "malformed value is NULL",
+			node: &JSONLScanNode{path: malformed, schema: map[string]ColumnType{"userId": TYPEINT, "ratedAt": TYPETIMESTAMP}, malformed: MALFORMEDNULL},
+			expected: []Tuple{
+				{data: map[string]interface{}{"userId": int64(1), "ratedAt": time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)}},
+				{data: map[string]interface{}{"userId": nil, "ratedAt": time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC)}},
+				{data: map[string]interface{}{"userId": int64(3), "ratedAt": nil}},
+			},
+		},
+		{
+			name: "malformed row is skipped",
+			node: &JSONLScanNode{path: malformed, schema: map[string]ColumnType{"userId": TYPEINT}, malformed: MALFORMEDSKIP},
+			expected: []Tuple{
+				{data: map[string]interface{}{"userId": int64(1)}},
+				{data: map[string]interface{}{"userId": int64(3)}},
+			},
+		},
+		{
+			name: "invalid JSON",
+			node: &JSONLScanNode{path: writeFile("invalid.jsonl", "{\"userId\": 1}\n{\"userId\": 2,\n")},
+			err:  "invalid.jsonl: line 2: unexpected EOF",
+		},
+		{
+			name: "not an object",
+			node: &JSONLScanNode{path: writeFile("array.jsonl", "[1, 2]\n")},
+			err:  "array.jsonl: line 1: json: cannot unmarshal array",
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.node})
+			if test.err != "" {
+				require.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+
+			/* a reset scan yields the same tuples */
+			require.NoError(t, InitPlanNode(test.node))
+			defer ClosePlanNode(test.node)
+			for run := 0; run < 2; run++ {
+				res := []Tuple{}
+				for {
+					tuple, err := test.node.next()
+					require.NoError(t, err)
+					if tuple.data == nil {
+						break
+					}
+					res = append(res, tuple)
+				}
+				require.Equal(t, test.expected, res)
+				require.NoError(t, test.node.reset())
+			}
+		})
+	}
+}
+
+func TestJSONLJoin(t *testing.T) {
+	dir := t.TempDir()
+	moviesPath, ratingsPath := filepath.Join(dir, "movies.csv"), filepath.Join(dir, "ratings.jsonl")
+	require.NoError(t, os.WriteFile(moviesPath, []byte("movieId,title\n1,Toy Story (1995)\n2,Jumanji (1995)\n"), 0666))
+	require.NoError(t, os.WriteFile(ratingsPath, []byte(`{"userId": 1, "movieId": 1, "rating": 4}`+"\n"+`{"userId": 2, "movieId": 2, "rating": 3.5}`+"\n"+`{"userId": 3, "rating": 1}`+"\n"), 0666))
+
+	qe := QueryExecutor{}
+	res, err := qe.ExecutePlan(&QueryDescriptor{
+		planNode: &HashJoinNode{reqHeaders: []string{"movieId", "movieId"}, partitionCount: 2, bloomFilter: true, headersInOrder: [][]string{{"movieId", "title"}, {"userId", "movieId", "rating"}}, inputs: []PlanNode{
+			&CSVScanNode{path: moviesPath, inferTypes: true},
+			&JSONLScanNode{path: ratingsPath, schema: map[string]ColumnType{"userId": TYPEINT, "movieId": TYPEINT, "rating": TYPEFLOAT}},
+		}},
+		qctx: &QueryContext{spill: NewSpillManager(t.TempDir(), 0)},
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Tuple{
+		{data: map[string]interface{}{"movieId": int64(1), "title": "Toy Story (1995)", "userId": int64(1), "rating": 4.0}},
+		{data: map[string]interface{}{"movieId": int64(2), "title": "Jumanji (1995)", "userId": int64(2), "rating": 3.5}},
+	}, res)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*** Materialize Node - spools the output of its input so rescans replay the spool instead of recomputing the subtree
+Tuples are held in memory up to memoryPages pages (or whatever the query's memory budget allows), the rest of the spool is spilled to a temp file and read back sequentially
+Tuples are passed on while the spool is being built, so the first pass streams like any other node
+reset() rewinds to the start of the spool without resetting the input, a reset before the input is exhausted replays the spool and then carries on pulling the input ***/
+
+const MATERIALIZEMEMORYPAGES = 1024 // default number of pages of the spool held in memory
+
+type MaterializeNode struct {
+	memoryPages int // pages of the spool held in memory, MATERIALIZEMEMORYPAGES if unset
+	inputs      []PlanNode
+	qctx        *QueryContext
+	mem         memoryReservation
+	stats       materializeStats
+
+	/* Spool state */
+	tuples     []Tuple // in-memory head of the spool
+	size       int     // bytes of tuples reserved from the memory budget
+	spilled    int     // tuples of the spool in the spill file, all following tuples
+	spillDir   string
+	spillBuf   bytes.Buffer // encoded tuples yet to be appended to the spill file
+	encoder    *gob.Encoder
+	inputDone  bool
+	pos        int // tuples of the spool yielded in the current pass
+	replayFile *os.File
+	decoder    *gob.Decoder
+}
+
+type materializeStats struct {
+	rescans       int // number of passes replayed from the spool
+	tuplesSpilled int
+}
+
+func (mn *MaterializeNode) init() error {
+	if mn.qctx == nil { // not run by an executor
+		mn.qctx = NewQueryContext()
+	}
+	mn.mem = memoryReservation{budget: mn.qctx.memory}
+	return nil
+}
+
+func (mn *MaterializeNode) setQueryContext(qctx *QueryContext) {
+	mn.qctx = qctx
+}
+
+func (mn *MaterializeNode) next() (Tuple, error) {
+	/* Replay the in-memory head, then the spilled tail of the spool */
+	if mn.pos < len(mn.tuples) {
+		mn.pos++
+		return mn.tuples[mn.pos-1], nil
+	}
+	if mn.pos < len(mn.tuples)+mn.spilled {
+		tuple, err := mn.nextSpilled()
+		if err != nil {
+			return Tuple{}, err
+		}
+		mn.pos++
+		return tuple, nil
+	}
+
+	/* Extend the spool with the input */
+	if mn.inputDone {
+		return Tuple{}, nil
+	}
+	tuple, err := mn.inputs[0].next()
+	if err != nil {
+		return Tuple{}, err
+	}
+	if tuple.data == nil {
+		mn.inputDone = true
+		return Tuple{}, mn.flushSpill()
+	}
+
+	err = mn.spool(tuple)
+	if err != nil {
+		return Tuple{}, err
+	}
+	mn.pos++
+	return tuple, nil
+}
+
+// appends a tuple to the spool, in memory while it fits and nothing has been spilled yet so the spool stays in order
+func (mn *MaterializeNode) spool(tuple Tuple) error {
+	memoryPages := mn.memoryPages
+	if memoryPages <= 0 {
+		memoryPages = MATERIALIZEMEMORYPAGES
+	}
+
+	size := sizeOfTuple(tuple)
+	if mn.spilled == 0 && mn.size+size <= memoryPages*PAGESIZE && mn.mem.grow(size) == nil {
+		mn.tuples = append(mn.tuples, tuple)
+		mn.size += size
+		return nil
+	}
+
+	if mn.encoder == nil {
+		spillDir, err := mn.qctx.spill.operatorDir("materialize")
+		if err != nil {
+			return err
+		}
+		mn.spillDir, mn.encoder = spillDir, gob.NewEncoder(&mn.spillBuf)
+	}
+	err := mn.encoder.Encode(encodeTuple(tuple))
+	if err != nil {
+		return err
+	}
+	mn.spilled++
+	mn.stats.tuplesSpilled++
+
+	if mn.spillBuf.Len() >= PAGESIZE {
+		return mn.flushSpill()
+	}
+	return nil
+}
+
+func (mn *MaterializeNode) flushSpill() error {
+	if mn.spillBuf.Len() == 0 {
+		return nil
+	}
+	err := mn.qctx.spill.appendFile(mn.spillDir, mn.spillPath(), mn.spillBuf.Bytes())
+	mn.spillBuf.Reset()
+	return err
+}
+
+func (mn *MaterializeNode) nextSpilled() (Tuple, error) {
+	if mn.decoder == nil {
+		err := mn.flushSpill()
+		if err != nil {
+			return Tuple{}, err
+		}
+		file, err := os.Open(mn.spillPath())
+		if err != nil {
+			return Tuple{}, err
+		}
+		mn.replayFile, mn.decoder = file, gob.NewDecoder(bufio.NewReader(file))
+	}
+
+	var et encodedTuple
+	err := mn.decoder.Decode(&et)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF // spilled counts tuples written, so the file can't end early
+		}
+		return Tuple{}, err
+	}
+	return et.decode(), nil
+}
+
+func (mn *MaterializeNode) spillPath() string {
+	return filepath.Join(mn.spillDir, "spool")
+}
+
+func (mn *MaterializeNode) closeReplay() error {
+	if mn.replayFile == nil {
+		return nil
+	}
+	err := mn.replayFile.Close()
+	mn.replayFile, mn.decoder = nil, nil
+	return err
+}
+
+func (mn *MaterializeNode) close() error {
+	err := mn.closeReplay()
+	mn.mem.releaseAll()
+	mn.tuples, mn.size, mn.spilled, mn.encoder, mn.inputDone, mn.pos = nil, 0, 0, nil, false, 0
+	mn.spillBuf.Reset()
+	if mn.spillDir != "" {
+		releaseErr := mn.qctx.spill.releaseDir(mn.spillDir)
+		mn.spillDir = ""
+		if err == nil {
+			err = releaseErr
+		}
+	}
+	return err
+}
+
+func (mn *MaterializeNode) getInputs() ([]PlanNode, error) {
+	return mn.inputs, nil
+}
+
+func (mn *MaterializeNode) reset() error { // replays the spool, the input is not reset
+	mn.pos = 0
+	mn.stats.rescans++
+	return mn.closeReplay()
+}
+
+func (mn *MaterializeNode) setInputs(inps []PlanNode) {
+	mn.inputs = inps
+}
+
+/*** Tuple encoding for spill files - gob can't encode nil interface values, so NULL headers are listed apart from the data ***/
+
+func init() {
+	gob.Register(time.Time{})
+}
+
+type encodedTuple struct {
+	Data  map[string]interface{}
+	Nulls []string
+}
+
+func encodeTuple(t Tuple) encodedTuple {
+	et := encodedTuple{Data: make(map[string]interface{}, len(t.data))}
+	for header, value := range t.data {
+		if value == nil {
+			et.Nulls = append(et.Nulls, header)
+			continue
+		}
+		et.Data[header] = value
+	}
+	return et
+}
+
+func (et encodedTuple) decode() Tuple {
+	tuple := Tuple{data: et.Data}
+	if tuple.data == nil {
+		tuple.data = map[string]interface{}{}
+	}
+	for _, header := range et.Nulls {
+		tuple.data[header] = nil
+	}
+	return tuple
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaterializeRescan(t *testing.T) {
+	movies, ratings := syntheticMovieRatings(40, 300, 60)
+	ratingsPath := writeRatingsCSV(t, ratings)
+
+	var expected []Tuple
+	for _, r := range ratings.data {
+		for _, m := range movies.data {
+			if r["movieId"] == m["movieId"] {
+				expected = append(expected, Tuple{data: map[string]interface{}{"movieId": m["movieId"], "title": m["title"], "userId": r["userId"]}})
+			}
+		}
+	}
+
+	tc := []struct {
+		name        string
+		memoryPages int
+		memory      int64
+		spills      bool
+	}{
+		{name: "in memory", memoryPages: 0, spills: false},
+		{name: "spilled beyond memory pages", memoryPages: 1, spills: true},
+		{name: "spilled beyond memory budget", memory: 20000, spills: true},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			inner := &countingNode{inputs: []PlanNode{&CSVScanNode{path: ratingsPath}}}
+			materialize := &MaterializeNode{memoryPages: test.memoryPages, inputs: []PlanNode{inner}}
+			join := &NaiveNestedJoinNode{headers: []string{"movieId", "movieId"}, inputs: []PlanNode{&TableScanNode{table: movies}, materialize}}
+
+			spillDir := t.TempDir()
+			qctx := &QueryContext{spill: NewSpillManager(spillDir, 0), memory: NewMemoryBudget(test.memory)}
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: &ProjectionNode{reqHeaders: []string{"movieId", "title", "userId"}, inputs: []PlanNode{join}}, qctx: qctx})
+			require.NoError(t, err)
+			require.ElementsMatch(t, expected, res)
+
+			require.Equal(t, len(movies.data), materialize.stats.rescans)
+			require.Equal(t, len(ratings.data)+1, inner.pulls, "the input must be read only once")
+			require.Equal(t, test.spills, materialize.stats.tuplesSpilled > 0)
+			require.Zero(t, qctx.memory.inUse())
+			entries, err := os.ReadDir(spillDir)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+		})
+	}
+}
+
+func TestMaterializeResetMidway(t *testing.T) {
+	_, ratings := syntheticMovieRatings(40, 300, 60)
+	ratings.data[3]["userId"] = nil // NULLs survive the spill file
+
+	input := &countingNode{inputs: []PlanNode{&TableScanNode{table: ratings}}}
+	materialize := &MaterializeNode{memoryPages: 1, inputs: []PlanNode{input}}
+	qctx := &QueryContext{spill: NewSpillManager(t.TempDir(), 0)}
+	qctx.fillDefaults()
+	SetQueryContextPlanNode(materialize, qctx)
+	require.NoError(t, InitPlanNode(materialize))
+
+	readAll := func(limit int) []Tuple {
+		res := []Tuple{}
+		for len(res) < limit {
+			tuple, err := materialize.next()
+			require.NoError(t, err)
+			if tuple.data == nil {
+				break
+			}
+			res = append(res, tuple)
+		}
+		return res
+	}
+
+	require.Len(t, readAll(250), 250)
+	require.NoError(t, materialize.reset())
+	first := readAll(len(ratings.data) + 1)
+	require.NoError(t, materialize.reset())
+	second := readAll(len(ratings.data) + 1)
+
+	expected := []Tuple{}
+	for _, r := range ratings.data {
+		expected = append(expected, Tuple{data: r})
+	}
+	require.Equal(t, expected, first)
+	require.Equal(t, expected, second)
+	require.Equal(t, len(ratings.data)+1, input.pulls)
+	require.Greater(t, materialize.stats.tuplesSpilled, 0)
+
+	require.NoError(t, ClosePlanNode(materialize))
+	require.NoError(t, qctx.spill.cleanup())
+	require.Zero(t, qctx.spill.inUse())
+}
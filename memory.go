@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+/*** Memory Budget - bytes of tuples a query's operators may hold in memory at once, operators reserve from it before buffering and release once done
+An operator that can't reserve either spills (hash join) or shrinks what it holds (nested loop chunks), and fails with errOutOfMemoryBudget if it can't hold even a single tuple ***/
+
+const QUERYMEMORYBUDGET = 256 << 20 // default budget of a query, 256MB
+
+var errOutOfMemoryBudget = fmt.Errorf("out of memory budget")
+
+type MemoryBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+	peak  int64
+}
+
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	if limit <= 0 {
+		limit = QUERYMEMORYBUDGET
+	}
+	return &MemoryBudget{limit: limit}
+}
+
+func (mb *MemoryBudget) reserve(n int64) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.used+n > mb.limit {
+		return fmt.Errorf("%w: %d of %d bytes in use, %d requested", errOutOfMemoryBudget, mb.used, mb.limit, n)
+	}
+	mb.used += n
+	if mb.used > mb.peak {
+		mb.peak = mb.used
+	}
+	return nil
+}
+
+func (mb *MemoryBudget) release(n int64) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.used -= n
+}
+
+func (mb *MemoryBudget) inUse() int64 {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return mb.used
+}
+
+// memoryReservation tracks the bytes one operator holds of the query's budget so they can all be handed back when it closes
+type memoryReservation struct {
+	budget *MemoryBudget
+	size   int64
+}
+
+func (mr *memoryReservation) grow(n int) error {
+	err := mr.budget.reserve(int64(n))
+	if err != nil {
+		return err
+	}
+	mr.size += int64(n)
+	return nil
+}
+
+func (mr *memoryReservation) shrink(n int) {
+	mr.budget.release(int64(n))
+	mr.size -= int64(n)
+}
+
+func (mr *memoryReservation) releaseAll() {
+	if mr.budget == nil {
+		return
+	}
+	mr.budget.release(mr.size)
+	mr.size = 0
+}
+
+/*** Tuple size estimation ***/
+
+const TUPLEOVERHEAD = 48      // header of the map backing a tuple
+const TUPLEENTRYOVERHEAD = 32 // string header of the key and interface header of the value, per field
+
+// estimated bytes a tuple occupies in memory, counting its map, keys and the values its fields point to
+func sizeOfTuple(t Tuple) int {
+	size := TUPLEOVERHEAD
+	for k, v := range t.data {
+		size += TUPLEENTRYOVERHEAD + len(k) + sizeOfValue(v)
+	}
+	return size
+}
+
+// bytes a value boxed in an interface points to
+func sizeOfValue(v interface{}) int {
+	switch val := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int(unsafe.Sizeof(val)) + len(val)
+	case []byte:
+		return int(unsafe.Sizeof(val)) + len(val)
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, int64, uint, uint64, float64:
+		return 8
+	case time.Time:
+		return int(unsafe.Sizeof(val))
+	}
+	return int(unsafe.Sizeof(v))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+)
+
+/*** Multi-file sources - a scan path resolves to one or more files read in lexical order as one table, see resolvePartitions
+Scans open and close the files themselves, multiFileSource keeps track of the file being read and adds the source and partition columns to the tuples of its files ***/
+
+type multiFileSource struct {
+	path         string          // a file, a directory or a glob pattern
+	sourceColumn string          // if set, every tuple carries the path of its file under this header
+	filters      []scanFilter    // pushed down by the optimizer, partitions they reject are never read
+	columns      []string        // required columns, every column if nil
+	hive         *hivePartitions // partition columns of every path, if path is a partitioned directory
+	paths        []string        // files path resolves to, those of partitions rejected by filters left out
+	idx          int             // file being read
+}
+
+type columnFilter struct {
+	idx   int // of the filtered column among the columns of the files
+	value string
+}
+
+// resolves the files of path and starts at the first one, there are none to read if every partition was pruned
+func (mfs *multiFileSource) resolve(path, sourceColumn string, filters []scanFilter, columns []string) error {
+	hive, err := resolvePartitions(path, filters)
+	if err != nil {
+		return err
+	}
+	*mfs = multiFileSource{path: path, sourceColumn: sourceColumn, filters: filters, columns: columns, hive: hive, paths: hive.paths}
+	return nil
+}
+
+func (mfs *multiFileSource) empty() bool {
+	return len(mfs.paths) == 0
+}
+
+func (mfs *multiFileSource) current() string {
+	return mfs.paths[mfs.idx]
+}
+
+// the source and partition columns share tuples with the columns of the files, kind names the latter in errors
+func (mfs *multiFileSource) checkColumns(columns []string, kind string) error {
+	if mfs.sourceColumn != "" && searchStringInList(mfs.sourceColumn, columns) != -1 {
+		return fmt.Errorf("%v: source column %v clashes with a %v", mfs.path, mfs.sourceColumn, kind)
+	}
+	return mfs.hive.checkColumns(mfs.path, append([]string{mfs.sourceColumn}, columns...))
+}
+
+// closes the current file and opens the next one, returns false if there is none
+func (mfs *multiFileSource) nextFile(closeFile, openFile func() error) (bool, error) {
+	if mfs.idx+1 >= len(mfs.paths) {
+		return false, nil
+	}
+	if err := closeFile(); err != nil {
+		return false, err
+	}
+	mfs.idx++
+	return true, openFile()
+}
+
+// whether each column of the files is required, nil if they all are
+func (mfs *multiFileSource) required(columns []string) []bool {
+	if mfs.columns == nil {
+		return nil
+	}
+	required := make([]bool, len(columns))
+	for i, column := range columns {
+		required[i] = isRequiredColumn(column, mfs.columns)
+	}
+	return required
+}
+
+// the filters on columns of the files, filters on partition columns were applied when resolving the files
+func (mfs *multiFileSource) columnFilters(columns []string) []columnFilter {
+	var cfs []columnFilter
+	for _, sf := range mfs.filters {
+		if i := searchStringInList(sf.header, columns); i != -1 {
+			cfs = append(cfs, columnFilter{idx: i, value: sf.value})
+		}
+	}
+	return cfs
+}
+
+// adds the source and partition columns of the current file to one of its tuples, only those that are required
+func (mfs *multiFileSource) addValues(tuple Tuple) {
+	if mfs.sourceColumn != "" && isRequiredColumn(mfs.sourceColumn, mfs.columns) {
+		tuple.data[mfs.sourceColumn] = mfs.current()
+	}
+	mfs.hive.addValues(tuple, mfs.idx, mfs.columns)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writes one ratings file per month as CSV, JSON Lines and YCFile, in directories of their own
+func writeMonthlyRatings(t *testing.T) (string, []Tuple) {
+	dir := t.TempDir()
+	expected := []Tuple{}
+	for _, format := range []string{"csv", "jsonl", "ycf"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, format), 0777))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, format, "_SUCCESS"), nil, 0666))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, format, ".ratings-2019-04."+format+".swp"), []byte("garbage"), 0666))
+	}
+
+	for _, month := range []int{3, 1, 2} { // written out of order, read in lexical order
+		ratings := Table{headers: []string{"userId", "movieId"}}
+		jsonl := ""
+		for i := 0; i < 5; i++ {
+			r := map[string]interface{}{"userId": strconv.Itoa(month*100 + i), "movieId": strconv.Itoa(i)}
+			ratings.data = append(ratings.data, r)
+			jsonl += fmt.Sprintf(`{"userId": %q, "movieId": %q}`+"\n", r["userId"], r["movieId"])
+		}
+		name := fmt.Sprintf("ratings-2019-%02d", month)
+		require.NoError(t, os.Rename(writeRatingsCSV(t, ratings), filepath.Join(dir, "csv", name+".csv")))
+		require.NoError(t, os.Rename(writeRatingsYCFile(t, ratings), filepath.Join(dir, "ycf", name+".ycf")))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "jsonl", name+".jsonl"), []byte(jsonl), 0666))
+	}
+
+	for _, month := range []int{1, 2, 3} {
+		for i := 0; i < 5; i++ {
+			expected = append(expected, Tuple{data: map[string]interface{}{"userId": strconv.Itoa(month*100 + i), "movieId": strconv.Itoa(i), "file": fmt.Sprintf("ratings-2019-%02d", month)}})
+		}
+	}
+	return dir, expected
+}
+
+func TestMultiFileScans(t *testing.T) {
+	dir, expected := writeMonthlyRatings(t)
+	firstTwoMonths := expected[:10]
+
+	tc := []struct {
+		name     string
+		node     PlanNode
+		expected []Tuple
+	}{
+		{name: "csv directory", node: &CSVScanNode{path: filepath.Join(dir, "csv"), sourceColumn: "file"}, expected: expected},
+		{name: "csv glob", node: &CSVScanNode{path: filepath.Join(dir, "csv", "ratings-2019-0[12].csv"), sourceColumn: "file"}, expected: firstTwoMonths},
+		{name: "json lines directory", node: &JSONLScanNode{path: filepath.Join(dir, "jsonl"), sourceColumn: "file"}, expected: expected},
+		{name: "json lines glob", node: &JSONLScanNode{path: filepath.Join(dir, "jsonl", "*-0[12].jsonl"), sourceColumn: "file"}, expected: firstTwoMonths},
+		{name: "ycfile directory", node: &FileScanNode{path: filepath.Join(dir, "ycf"), sourceColumn: "file"}, expected: expected},
+		{name: "ycfile glob", node: &FileScanNode{path: filepath.Join(dir, "ycf", "ratings-*-0[12].ycf"), sourceColumn: "file"}, expected: firstTwoMonths},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, InitPlanNode(test.node))
+			defer ClosePlanNode(test.node)
+
+			for run := 0; run < 2; run++ { // the second run checks reset from a later file
+				res := []Tuple{}
+				for {
+					tuple, err := test.node.next()
+					require.NoError(t, err)
+					if tuple.data == nil {
+						break
+					}
+					file := filepath.Base(tuple.data["file"].(string))
+					tuple.data["file"] = file[:len(file)-len(filepath.Ext(file))]
+					res = append(res, tuple)
+				}
+				require.Equal(t, test.expected, res)
+				require.NoError(t, test.node.reset())
+			}
+		})
+	}
+}
+
+func TestMultiFileScanErrors(t *testing.T) {
+	dir, _ := writeMonthlyRatings(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "csv", "ratings-2019-05.csv"), []byte("userId,movieId,rating\n1,2,3\n"), 0666))
+	other := writeRatingsYCFile(t, Table{headers: []string{"userId", "rating"}, data: []map[string]interface{}{{"userId": "1", "rating": "4"}}})
+	require.NoError(t, os.Rename(other, filepath.Join(dir, "ycf", "ratings-2019-05.ycf")))
+
+	tc := []struct {
+		name string
+		node PlanNode
+		err  string
+	}{
+		{name: "csv headers differ", node: &CSVScanNode{path: filepath.Join(dir, "csv")}, err: "ratings-2019-05.csv: header [userId movieId rating] doesn't match header [userId movieId]"},
+		{name: "ycfile fields differ", node: &FileScanNode{path: filepath.Join(dir, "ycf")}, err: "ratings-2019-05.ycf: fields [userId rating]"},
+		{name: "nothing matches", node: &CSVScanNode{path: filepath.Join(dir, "csv", "*.tsv")}, err: "no such file or directory"},
+		{name: "empty directory", node: &JSONLScanNode{path: t.TempDir()}, err: "no files to scan"},
+		{name: "source column clashes", node: &CSVScanNode{path: filepath.Join(dir, "csv"), sourceColumn: "userId"}, err: "source column userId clashes with a header"},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			qe := QueryExecutor{}
+			_, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.node})
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
@@ -3,17 +3,21 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
+	"encoding/gob"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"unsafe"
+	"time"
 
 	"github.com/chettriyuvraj/query-executor/ycfile"
 )
 
-const PAGESIZE = 8196
+const PAGESIZE = ycfile.PAGESIZE
 
 type Tuple struct {
 	data map[string]interface{}
@@ -23,7 +27,7 @@ type PlanNode interface { /* This is the iterator interface, every PlanNode will
 	init() error
 	next() (Tuple, error)
 	close() error
-	reset() error
+	reset() error // rewinds an initialised node so it yields its tuples again from the first one, e.g. to rescan the inner input of a nested loop join - nodes drop their own state and reset their inputs
 	getInputs() ([]PlanNode, error)
 	setInputs(inps []PlanNode)
 }
@@ -95,6 +99,7 @@ func (tn *TableScanNode) getInputs() ([]PlanNode, error) {
 }
 
 func (tn *TableScanNode) reset() error {
+	tn.tableIdx = 0
 	return resetPlanNode(tn)
 }
 
@@ -105,67 +110,275 @@ func (tn *TableScanNode) setInputs(inps []PlanNode) {
 /*** CSV Scan Node ***/
 
 type CSVScanNode struct {
-	idx     int
-	file    *os.File
-	scanner *bufio.Scanner
-	path    string
-	headers []string
-	// delimiter string Assuming newline as the delimiter always for now
-	inputs []PlanNode
+	idx          int
+	file         *sourceFile // gzipped files are decompressed on the fly
+	reader       *csvReader
+	path         string                // a file, a directory or a glob pattern - several files are read in lexical order as one table and must have the same header
+	files        multiFileSource       // files path resolves to
+	sourceColumn string                // if set, every tuple carries the path of its file under this header
+	filters      []scanFilter          // pushed down by the optimizer, partitions they reject are never read and rows they reject never become tuples
+	headers      []string              // read from the first record, unless headerless
+	columns      []string              // required columns, every column if nil - cells of the other columns are only parsed when malformed rows are skipped
+	required     []bool                // whether each header is a required column
+	rowFilters   []columnFilter        // filters on the columns of the files
+	headerless   bool                  // every record is data, columns are named by headers if set or column1, column2... otherwise
+	delimiter    rune                  // CSVDELIMITER if unset
+	quote        rune                  // CSVQUOTE if unset
+	schema       map[string]ColumnType // explicit column types, overriding inferred ones
+	inferTypes   bool                  // infer the types of columns missing from schema from the first sampleSize records, they are strings otherwise
+	sampleSize   int                   // CSVSAMPLEROWS if unset
+	malformed    int                   // MALFORMEDERROR, MALFORMEDNULL or MALFORMEDSKIP for cells that don't parse as their column's type
+	columnTypes  []ColumnType          // type of each header
+	pending      []csvRecord           // records read ahead by init, returned before reading on
+	bloom        *bloomProbe           // pushed down from a hash join, nil if none
+	inputs       []PlanNode
+}
+
+type csvRecord struct {
+	fields []string
+	line   int
+	err    error
 }
 
 func (csvn *CSVScanNode) init() error {
-	file, err := os.Open(csvn.path)
+	err := csvn.files.resolve(csvn.path, csvn.sourceColumn, csvn.filters, csvn.columns)
+	if err != nil {
+		return err
+	}
+	if csvn.files.empty() { // every partition was pruned
+		return nil
+	}
+
+	err = csvn.openFile()
+	if err != nil {
+		return err
+	}
+	err = csvn.files.checkColumns(csvn.headers, "header")
 	if err != nil {
 		return err
 	}
 
-	csvn.scanner = bufio.NewScanner(file)
+	csvn.required = csvn.files.required(csvn.headers)
+	csvn.rowFilters = csvn.files.columnFilters(csvn.headers)
+	return csvn.resolveColumnTypes()
+}
+
+// opens the current file and reads its header, the header of every file after the first must match the first one's
+func (csvn *CSVScanNode) openFile() error {
+	path := csvn.files.current()
+	file, err := openSource(path)
+	if err != nil {
+		return err
+	}
 	csvn.file = file
-	dataExists := csvn.scanner.Scan()
-	if !dataExists {
-		if err := csvn.scanner.Err(); err != nil {
-			return err
+
+	csvn.reader, err = newCSVReader(file, csvn.delimiter, csvn.quote)
+	if err != nil {
+		return err
+	}
+
+	if csvn.headerless && (csvn.headers != nil || csvn.files.idx > 0) {
+		return nil
+	}
+
+	record, line, err := csvn.reader.read()
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+
+	switch {
+	case err == io.EOF && !csvn.headerless:
+		return fmt.Errorf("%v: no header row found", path)
+	case csvn.headerless:
+		if err != io.EOF {
+			csvn.pending = append(csvn.pending, csvRecord{fields: record, line: line})
+		}
+		for i := range record {
+			csvn.headers = append(csvn.headers, fmt.Sprintf("column%d", i+1))
+		}
+	case csvn.files.idx == 0:
+		csvn.headers = record
+	case strings.Join(record, "\x1f") != strings.Join(csvn.headers, "\x1f"):
+		return fmt.Errorf("%v: header %v doesn't match header %v of %v", path, record, csvn.headers, csvn.files.paths[0])
+	}
+	return nil
+}
+
+// sets the type of every column from schema, or from a sample of the records when inferring types
+func (csvn *CSVScanNode) resolveColumnTypes() error {
+	for header := range csvn.schema {
+		if searchStringInList(header, csvn.headers) == -1 {
+			return fmt.Errorf("%v: schema column %v not in headers %v", csvn.path, header, csvn.headers)
+		}
+	}
+
+	sampleSize := csvn.sampleSize
+	if sampleSize <= 0 {
+		sampleSize = CSVSAMPLEROWS
+	}
+	for csvn.inferTypes && len(csvn.pending) < sampleSize {
+		fields, line, err := csvn.reader.read()
+		if err == io.EOF {
+			break
+		}
+		csvn.pending = append(csvn.pending, csvRecord{fields: fields, line: line, err: err})
+		if err != nil { // returned once the scan gets to it
+			break
+		}
+	}
+
+	csvn.columnTypes = make([]ColumnType, len(csvn.headers))
+	for i, header := range csvn.headers {
+		if ct, exists := csvn.schema[header]; exists {
+			csvn.columnTypes[i] = ct
+			continue
+		}
+		if !csvn.inferTypes {
+			continue
 		}
-		return fmt.Errorf("no header row found")
+
+		values := []string{}
+		for _, record := range csvn.pending {
+			if record.err == nil && len(record.fields) == len(csvn.headers) {
+				values = append(values, record.fields[i])
+			}
+		}
+		csvn.columnTypes[i] = inferColumnType(values)
 	}
-	csvn.headers = strings.Split(csvn.scanner.Text(), ",")
 	return nil
 }
 
 func (csvn *CSVScanNode) next() (Tuple, error) {
-	// Get data from scanner
-	dataExists := csvn.scanner.Scan()
-	if !dataExists {
-		if err := csvn.scanner.Err(); err != nil {
+	if csvn.files.empty() {
+		return Tuple{}, nil // EOF
+	}
+
+	for {
+		var record csvRecord
+		if len(csvn.pending) > 0 {
+			record, csvn.pending = csvn.pending[0], csvn.pending[1:]
+		} else {
+			record.fields, record.line, record.err = csvn.reader.read()
+		}
+		if record.err == io.EOF {
+			more, err := csvn.files.nextFile(csvn.closeFile, csvn.openFile)
+			if err != nil {
+				return Tuple{}, err
+			}
+			if !more {
+				return Tuple{}, nil // EOF
+			}
+			continue
+		}
+		if record.err != nil {
+			return Tuple{}, fmt.Errorf("%v: %w", csvn.files.current(), record.err)
+		}
+
+		if len(record.fields) != len(csvn.headers) {
+			err := &csvParseError{line: record.line, err: fmt.Errorf("%w: expected %d, got %d", errCSVFieldCount, len(csvn.headers), len(record.fields))}
+			return Tuple{}, fmt.Errorf("%v: %w", csvn.files.current(), err)
+		}
+
+		if !csvn.matchFilters(record) {
+			csvn.idx++
+			continue
+		}
+
+		// Add data to tuple according to headers (headers arranged in order of occurrence of field in file)
+		tuple, keep, err := csvn.recordToTuple(record)
+		if err != nil {
 			return Tuple{}, err
 		}
-		return Tuple{}, nil // EOF
+		csvn.idx++
+
+		if !keep || (csvn.bloom != nil && !csvn.bloom.mayMatch(tuple)) {
+			continue
+		}
+
+		return tuple, nil
 	}
+}
+
+// evaluates the pushed down filters on the raw fields of a record, only the cells of filtered typed columns are parsed
+func (csvn *CSVScanNode) matchFilters(record csvRecord) bool {
+	for _, rf := range csvn.rowFilters {
+		value, err := parseValue(record.fields[rf.idx], csvn.columnTypes[rf.idx])
+		if err != nil {
+			if csvn.malformed != MALFORMEDERROR { // a NULL or skipped row never matches
+				return false
+			}
+			continue // recordToTuple fails the scan unless another filter rejects the row
+		}
+		if eq, _ := valuesEqual(value, rf.value); !eq {
+			return false
+		}
+	}
+	return true
+}
 
-	// Add data to tuple according to headers (assume headers arranged in order of occurrence of field in file)
-	tuple := Tuple{}
-	textData := strings.Split(csvn.scanner.Text(), ",")
-	tuple.data = map[string]interface{}{}
+// converts the fields of a record to their column's type, keep is false if the row is to be skipped for a malformed cell
+func (csvn *CSVScanNode) recordToTuple(record csvRecord) (tuple Tuple, keep bool, err error) {
+	tuple = Tuple{data: make(map[string]interface{}, len(csvn.headers))}
 	for i, header := range csvn.headers {
-		tuple.data[header] = textData[i]
+		required := csvn.required == nil || csvn.required[i]
+		if !required && csvn.malformed != MALFORMEDSKIP { // cells of other columns still decide which rows are skipped
+			continue
+		}
+		value, err := parseValue(record.fields[i], csvn.columnTypes[i])
+		if err != nil {
+			switch csvn.malformed {
+			case MALFORMEDNULL:
+				value = nil
+			case MALFORMEDSKIP:
+				return Tuple{}, false, nil
+			default:
+				parseErr := &csvParseError{line: record.line, err: fmt.Errorf("column %v: %w", header, err)}
+				return Tuple{}, false, fmt.Errorf("%v: %w", csvn.files.current(), parseErr)
+			}
+		}
+		if required {
+			tuple.data[header] = value
+		}
 	}
+	csvn.files.addValues(tuple)
+	return tuple, true, nil
+}
 
-	csvn.idx++
+func (csvn *CSVScanNode) setBloomProbe(bp *bloomProbe) {
+	csvn.bloom = bp
+}
 
-	return tuple, nil
+func (csvn *CSVScanNode) addScanFilter(sf scanFilter) {
+	csvn.filters = addScanFilter(csvn.filters, sf)
+}
+
+func (csvn *CSVScanNode) setRequiredColumns(columns []string) {
+	csvn.columns = columns
 }
 
 func (csvn *CSVScanNode) close() error {
-	return csvn.file.Close()
+	return csvn.closeFile()
+}
+
+func (csvn *CSVScanNode) closeFile() error {
+	if csvn.file == nil { // never initialised
+		return nil
+	}
+	err := csvn.file.Close()
+	csvn.file = nil
+	return err
 }
 
 func (csvn *CSVScanNode) getInputs() ([]PlanNode, error) {
 	return csvn.inputs, nil
 }
 
-func (csvn *CSVScanNode) reset() error {
-	csvn.idx = 0
+func (csvn *CSVScanNode) reset() error { // reopens the file, so the header row is skipped again
+	err := csvn.close()
+	if err != nil {
+		return err
+	}
+	csvn.idx, csvn.pending = 0, nil
 	return csvn.init()
 }
 
@@ -176,53 +389,196 @@ func (csvn *CSVScanNode) setInputs(inps []PlanNode) {
 /*** YCF File Scan Node ***/
 
 type FileScanNode struct {
-	idx    int
-	reader *ycfile.YCFileReader
-	path   string
-	inputs []PlanNode
+	end            int // record index the scan stops at
+	reader         *ycfile.YCFileReader
+	path           string          // a file, a directory or a glob pattern - several files are read in lexical order as one table and must have the same fields
+	files          multiFileSource // files path resolves to
+	sourceColumn   string          // if set, every tuple carries the path of its file under this header
+	filters        []scanFilter    // pushed down by the optimizer, partitions they reject are never read and records they reject are never decoded
+	fields         []string        // fields of the first file
+	columns        []string        // required columns, every column if nil - the other fields are never decoded
+	fieldTypes     []byte
+	partition      int // with partitionCount > 1 only the partition-th of partitionCount contiguous record ranges of each file is read, e.g. one per exchange worker
+	partitionCount int
+	bloom          *bloomProbe // pushed down from a hash join, nil if none
+	inputs         []PlanNode
 }
 
 func (fsn *FileScanNode) init() error {
-	reader, err := ycfile.NewYCFileReader(fsn.path)
+	err := fsn.files.resolve(fsn.path, fsn.sourceColumn, fsn.filters, fsn.columns)
+	if err != nil {
+		return err
+	}
+	if fsn.files.empty() { // every partition was pruned
+		return nil
+	}
+
+	return fsn.openFile()
+}
+
+// opens the current file at the start of its range, the fields of every file after the first must match the first one's
+func (fsn *FileScanNode) openFile() error {
+	reader, err := ycfile.NewYCFileReader(fsn.files.current())
 	if err != nil {
 		return err
 	}
 	fsn.reader = reader
 
+	if fsn.files.idx == 0 {
+		if err := fsn.files.checkColumns(reader.Fields(), "field"); err != nil {
+			return err
+		}
+		fsn.fields, fsn.fieldTypes = reader.Fields(), reader.FieldTypes()
+	} else if strings.Join(reader.Fields(), "\x1f") != strings.Join(fsn.fields, "\x1f") || !bytes.Equal(reader.FieldTypes(), fsn.fieldTypes) {
+		return fmt.Errorf("%v: fields %v %v don't match fields %v %v of %v", fsn.files.current(), reader.Fields(), reader.FieldTypes(), fsn.fields, fsn.fieldTypes, fsn.files.paths[0])
+	}
+
+	if required := fsn.files.required(fsn.fields); required != nil {
+		selected := []string{}
+		for i, field := range fsn.fields {
+			if required[i] {
+				selected = append(selected, field)
+			}
+		}
+		if err := reader.SelectFields(selected); err != nil {
+			return err
+		}
+	}
+
+	fieldFilters := []ycfile.FieldFilter{}
+	for _, cf := range fsn.files.columnFilters(fsn.fields) {
+		fieldFilters = append(fieldFilters, ycfile.FieldFilter{Field: fsn.fields[cf.idx], Match: fieldMatcher(fsn.fieldTypes[cf.idx], cf.value)})
+	}
+	if err := reader.SetFieldFilters(fieldFilters); err != nil {
+		return err
+	}
+	return fsn.seekRangeStart()
+}
+
+// positions the reader at the first record of the scanned range
+func (fsn *FileScanNode) seekRangeStart() error {
+	recordCount := fsn.reader.RecordCount()
+	fsn.end = recordCount
+	if fsn.partitionCount > 1 {
+		if fsn.partition < 0 || fsn.partition >= fsn.partitionCount {
+			return fmt.Errorf("partition %v out of range for %v partitions", fsn.partition, fsn.partitionCount)
+		}
+		start := recordCount * fsn.partition / fsn.partitionCount
+		fsn.end = recordCount * (fsn.partition + 1) / fsn.partitionCount
+		if err := fsn.reader.Seek(start); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (fsn *FileScanNode) next() (Tuple, error) {
-	ycfRecord, err := fsn.reader.Read()
-	if err != nil {
-		if err == io.EOF {
-			return Tuple{}, nil // EOF
+	if fsn.files.empty() {
+		return Tuple{}, nil // EOF
+	}
+
+	for {
+		ycfRecord, err := fsn.reader.ReadBefore(fsn.end)
+		if err == io.EOF { // end of this file's range, move on to the next file
+			more, err := fsn.files.nextFile(fsn.close, fsn.openFile)
+			if err != nil {
+				return Tuple{}, err
+			}
+			if !more {
+				return Tuple{}, nil // EOF
+			}
+			continue
 		}
-		return Tuple{}, err
+		if err != nil {
+			return Tuple{}, err
+		}
+
+		tuple := ycfRecordToTuple(ycfRecord) // This tuple contains the required fields only, all of them unless columns were pushed down
+		fsn.files.addValues(tuple)
+
+		if fsn.bloom != nil && !fsn.bloom.mayMatch(tuple) {
+			continue
+		}
+
+		return tuple, err //  Should we be converting or should everything be returned as YCFRecord?
 	}
+}
+
+func (fsn *FileScanNode) setBloomProbe(bp *bloomProbe) {
+	fsn.bloom = bp
+}
 
-	fsn.idx++
-	tuple := ycfRecordToTuple(ycfRecord) // This tuple contains all fields in table - filtering is handled by projection nodes
+func (fsn *FileScanNode) addScanFilter(sf scanFilter) {
+	fsn.filters = addScanFilter(fsn.filters, sf)
+}
 
-	return tuple, err //  Should we be converting or should everything be returned as YCFRecord?
+func (fsn *FileScanNode) setRequiredColumns(columns []string) {
+	fsn.columns = columns
 }
 
 func (fsn *FileScanNode) close() error {
-	return fsn.reader.Close()
+	if fsn.reader == nil { // never initialised
+		return nil
+	}
+	err := fsn.reader.Close()
+	fsn.reader = nil
+	return err
 }
 
 func (fsn *FileScanNode) getInputs() ([]PlanNode, error) {
 	return fsn.inputs, nil
 }
 
-func (fsn *FileScanNode) reset() error {
-	return nil // TO DO: implement reset
+func (fsn *FileScanNode) reset() error { // seeks back to the first record instead of reopening the file, unless a later file is being read
+	if fsn.files.empty() {
+		return resetPlanNode(fsn)
+	}
+	if fsn.files.idx == 0 {
+		err := fsn.reader.Rewind()
+		if err != nil {
+			return err
+		}
+		err = fsn.seekRangeStart()
+		if err != nil {
+			return err
+		}
+	} else {
+		err := fsn.close()
+		if err != nil {
+			return err
+		}
+		fsn.files.idx = 0
+		err = fsn.openFile()
+		if err != nil {
+			return err
+		}
+	}
+	return resetPlanNode(fsn)
 }
 
 func (fsn *FileScanNode) setInputs(inps []PlanNode) {
 	fsn.inputs = inps
 }
 
+// matches the raw bytes of a field against a filter value the way FilterNode would match its decoded value
+func fieldMatcher(fieldType byte, value string) func(val []byte) bool {
+	if _, isNumber := toNumber(value); ycfile.IsStringType(fieldType) && !isNumber { // strings only compare as numbers with numbers, no need to decode them
+		raw := []byte(value)
+		return func(val []byte) bool {
+			return bytes.Equal(val, raw)
+		}
+	}
+	return func(val []byte) bool {
+		decoded, err := ycfile.DecodeValue(fieldType, val)
+		if err != nil { // corrupt values are left to Read to report when it decodes them
+			return true
+		}
+		eq, _ := valuesEqual(decoded, value)
+		return eq
+	}
+}
+
 func ycfRecordToTuple(ycfRecord ycfile.YCFileRecord) Tuple {
 	tuple := Tuple{data: make(map[string]interface{})}
 	for _, pair := range ycfRecord.Data {
@@ -318,6 +674,7 @@ func (ln *LimitNode) getInputs() ([]PlanNode, error) {
 }
 
 func (ln *LimitNode) reset() error {
+	ln.offset = 0
 	return resetPlanNode(ln)
 }
 
@@ -329,7 +686,8 @@ func (ln *LimitNode) setInputs(inps []PlanNode) {
 type FilterNode struct { // single condition
 	header   string // header on which we are checking condition
 	operator string
-	cmpValue string // assuming all values string for now
+	cmpValue string      // assuming all values string for now
+	bloom    *bloomProbe // pushed down from a hash join, nil if none
 	inputs   []PlanNode
 }
 
@@ -338,25 +696,37 @@ func (fn *FilterNode) init() error {
 }
 
 func (fn *FilterNode) next() (Tuple, error) {
-	nextTuple, err := fn.inputs[0].next()
-	if err != nil {
-		return Tuple{}, err
-	}
+	for {
+		nextTuple, err := fn.inputs[0].next()
+		if err != nil {
+			return Tuple{}, err
+		}
+
+		if nextTuple.data == nil {
+			return nextTuple, nil
+		}
+
+		if fn.bloom != nil && !fn.bloom.mayMatch(nextTuple) {
+			continue
+		}
 
-	if nextTuple.data != nil {
 		switch op := fn.operator; op {
 		case "=":
 			value, exists := nextTuple.data[fn.header]
 			if !exists {
 				return Tuple{}, fmt.Errorf("header %v doesn't exist to filter", fn.header)
 			}
-			if value != fn.cmpValue {
-				return fn.next()
+			if eq, _ := valuesEqual(value, fn.cmpValue); !eq { // typed values compare with cmpValue as numbers, NULLs never match
+				continue
 			}
 		}
+
+		return nextTuple, nil
 	}
+}
 
-	return nextTuple, nil
+func (fn *FilterNode) setBloomProbe(bp *bloomProbe) {
+	fn.bloom = bp
 }
 
 func (fn *FilterNode) close() error {
@@ -376,8 +746,16 @@ func (fn *FilterNode) setInputs(inps []PlanNode) {
 }
 
 /*** Average Node ***/
+// aggregation modes, so an average can be split into partial aggregations run in parallel below an exchange and a final one above it
+const (
+	AGGCOMPLETE = iota // input rows to average
+	AGGPARTIAL         // input rows to sum and count
+	AGGFINAL           // partial sums and counts to average
+)
+
 type AvgNode struct { // single condition
 	header string // header on which we are checking average
+	mode   int
 	inputs []PlanNode
 }
 
@@ -398,13 +776,22 @@ func (an *AvgNode) next() (Tuple, error) {
 			break
 		}
 
-		field := nextTuple.data[an.header]
-		switch f := field.(type) {
-		case string: // assuming only strings for now
-			v, err := strconv.ParseFloat(f, 64)
-			if err != nil {
-				return Tuple{}, err
+		if an.mode == AGGFINAL {
+			sum, okSum := nextTuple.data["sum"].(float64)
+			partialCount, okCount := nextTuple.data["count"].(int)
+			if !okSum || !okCount {
+				return Tuple{}, fmt.Errorf("final average expects partial sum and count, got %v", nextTuple.data)
 			}
+			total += sum
+			count += partialCount
+			continue
+		}
+
+		v, ok, err := averageValue(nextTuple.data[an.header])
+		if err != nil {
+			return Tuple{}, err
+		}
+		if ok {
 			total += v
 			count++
 		}
@@ -414,9 +801,28 @@ func (an *AvgNode) next() (Tuple, error) {
 		return Tuple{}, nil
 	}
 
+	if an.mode == AGGPARTIAL {
+		return Tuple{data: map[string]interface{}{"sum": total, "count": count}}, nil
+	}
 	return Tuple{data: map[string]interface{}{"average": total / float64(count)}}, nil
 }
 
+// numeric value of a field to be averaged, ok is false for NULLs and other values that are left out of the average
+func averageValue(field interface{}) (v float64, ok bool, err error) {
+	switch f := field.(type) {
+	case string: // text sources without a schema
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return 0, false, err
+		}
+		return v, true, nil
+	case int, int32, int64, float64:
+		v, _ := toFloat(f)
+		return v, true, nil
+	}
+	return 0, false, nil
+}
+
 func (an *AvgNode) close() error {
 	return nil
 }
@@ -478,51 +884,60 @@ func (an *AvgNode) setInputs(inps []PlanNode) {
 
 /*** Naive Nested Join Node ***/
 
-type NaiveNestedJoinNode struct { // single condition
-	headers []string // headers on which we are doing the join -> inputs[0] -> header[0] -> inputs[1] -> headers[1]
-	inputs  []PlanNode
-	res     []Tuple
-	idx     int
+type NaiveNestedJoinNode struct {
+	headers   []string // headers on which we are doing an equi-join -> inputs[0] -> header[0] -> inputs[1] -> headers[1]
+	predicate Expr     // arbitrary join condition, used instead of headers if set
+	inputs    []PlanNode
+	outer     Tuple // current tuple of inputs[0] the inner input is being streamed past
+	outerDone bool
 }
 
 func (njn *NaiveNestedJoinNode) init() error {
 	return nil
 }
 
-func (njn *NaiveNestedJoinNode) next() (Tuple, error) {
-	if njn.idx == 0 { // if join hasn't been performed - first perform complete join and then return elems one by one
-		inp1, inp2 := njn.inputs[0], njn.inputs[1]
-		h1, h2 := njn.headers[0], njn.headers[1]
+func (njn *NaiveNestedJoinNode) next() (Tuple, error) { // yields matches as they are found, the inner input is reset once per outer tuple
+	inp1, inp2 := njn.inputs[0], njn.inputs[1]
+
+	for {
+		if njn.outer.data == nil {
+			if njn.outerDone {
+				return Tuple{}, nil
+			}
 
-		for t1, err := inp1.next(); t1.data != nil; t1, err = inp1.next() {
+			t1, err := inp1.next()
 			if err != nil {
 				return Tuple{}, err
 			}
+			if t1.data == nil {
+				njn.outerDone = true
+				return Tuple{}, nil
+			}
+			njn.outer = t1
+		}
 
-			for t2, err := inp2.next(); t2.data != nil; t2, err = inp2.next() {
-				if err != nil {
-					return Tuple{}, err
-				}
+		t2, err := inp2.next()
+		if err != nil {
+			return Tuple{}, err
+		}
 
-				if t1.data[h1] == t2.data[h2] {
-					njn.res = append(njn.res, combineTuples(t1, t2))
-				}
-			}
+		if t2.data == nil { // inner exhausted for this outer tuple, rewind it for the next one
 			err := inp2.reset()
 			if err != nil {
 				return Tuple{}, err
 			}
+			njn.outer = Tuple{}
+			continue
 		}
-	}
 
-	if njn.idx >= len(njn.res) {
-		return Tuple{}, nil
+		match, err := joinMatches(njn.headers, njn.predicate, njn.outer, t2)
+		if err != nil {
+			return Tuple{}, err
+		}
+		if match {
+			return combineTuples(njn.outer, t2), nil
+		}
 	}
-
-	resTuple := njn.res[njn.idx]
-	njn.idx++
-	return resTuple, nil
-
 }
 
 func (njn *NaiveNestedJoinNode) close() error {
@@ -534,6 +949,7 @@ func (njn *NaiveNestedJoinNode) getInputs() ([]PlanNode, error) {
 }
 
 func (njn *NaiveNestedJoinNode) reset() error {
+	njn.outer, njn.outerDone = Tuple{}, false
 	return resetPlanNode(njn)
 }
 
@@ -541,6 +957,15 @@ func (njn *NaiveNestedJoinNode) setInputs(inps []PlanNode) {
 	njn.inputs = inps
 }
 
+// checks a candidate pair against the join predicate if one is set, otherwise compares the join headers for equality
+func joinMatches(headers []string, predicate Expr, t1 Tuple, t2 Tuple) (bool, error) {
+	if predicate != nil {
+		return evalPredicate(predicate, joinedRow{left: t1, right: t2})
+	}
+	eq, _ := valuesEqual(t1.data[headers[0]], t2.data[headers[1]])
+	return eq, nil
+}
+
 func combineTuples(t1 Tuple, t2 Tuple) Tuple { // assuming no keys of the same name
 	ct := Tuple{data: map[string]interface{}{}}
 	for k, v := range t1.data {
@@ -554,82 +979,119 @@ func combineTuples(t1 Tuple, t2 Tuple) Tuple { // assuming no keys of the same n
 
 /*** Chunk Oriented Nested Join - For Page Oriented Nested Join, simply set the numberOfPages to 1 ***/
 
-type ChunkNestedJoinNode struct { // single condition
-	headers       []string // headers on which we are doing the join -> inputs[0] -> header[0] -> inputs[1] -> headers[1]
+type ChunkNestedJoinNode struct {
+	headers       []string // headers on which we are doing an equi-join -> inputs[0] -> header[0] -> inputs[1] -> headers[1]
+	predicate     Expr     // arbitrary join condition, used instead of headers if set
 	inputs        []PlanNode
-	res           []Tuple
-	idx           int
 	numberOfPages int // number of r1 pages to hold in memory before iterating over r2
 	carryOverData Tuple
+	chunk         []Tuple // current chunk of r1, nil when the next chunk is yet to be read
+	chunkSize     int     // bytes of chunk reserved from the query's memory budget
+	chunkIdx      int     // position in chunk the current inner tuple is to be compared from
+	inner         Tuple   // current tuple of r2 being matched against the chunk
+	qctx          *QueryContext
+	mem           memoryReservation
 }
 
 func (njn *ChunkNestedJoinNode) init() error {
+	if njn.qctx == nil { // not run by an executor
+		njn.qctx = NewQueryContext()
+	}
+	njn.mem = memoryReservation{budget: njn.qctx.memory}
 	return nil
 }
 
-func (njn *ChunkNestedJoinNode) next() (Tuple, error) { // TODO: Refactor and make it easier to read
-	if njn.idx == 0 { // if join hasn't been performed - first perform complete join and then return elems one by one
-		inp1, inp2 := njn.inputs[0], njn.inputs[1]
-		h1, h2 := njn.headers[0], njn.headers[1]
+func (njn *ChunkNestedJoinNode) setQueryContext(qctx *QueryContext) {
+	njn.qctx = qctx
+}
 
-		for {
-			/* Check if any data exists either in input or as carryover from previous pass */
-			t1, err := inp1.next()
+func (njn *ChunkNestedJoinNode) next() (Tuple, error) { // yields matches as they are found, every r2 tuple is compared against the whole chunk before moving on
+	inp2 := njn.inputs[1]
+
+	for {
+		/* Match the current inner tuple against the rest of the chunk */
+		for njn.chunkIdx < len(njn.chunk) {
+			t1 := njn.chunk[njn.chunkIdx]
+			njn.chunkIdx++
+			match, err := joinMatches(njn.headers, njn.predicate, t1, njn.inner)
 			if err != nil {
 				return Tuple{}, err
 			}
-
-			if t1.data == nil && njn.carryOverData.data == nil {
-				break
+			if match {
+				return combineTuples(t1, njn.inner), nil
 			}
+		}
 
-			/* Create page slice, add carry over data from last pass, and fill page until PAGESIZE data is filled */
-			page1data := []Tuple{t1}
-			page1Size := sizeOfTuple(t1)
-			if njn.carryOverData.data != nil {
-				page1data = append(page1data, njn.carryOverData)
-				page1Size += sizeOfTuple(njn.carryOverData)
+		/* Bring the next chunk of r1 into memory once r2 has been streamed past the current one */
+		if njn.chunk == nil {
+			chunk, err := njn.nextChunk()
+			if err != nil {
+				return Tuple{}, err
 			}
-
-			for njn.carryOverData, err = inp1.next(); njn.carryOverData.data != nil && page1Size+sizeOfTuple(njn.carryOverData) <= PAGESIZE*njn.numberOfPages; njn.carryOverData, err = inp1.next() {
-				if err != nil {
-					return Tuple{}, err
-				}
-				page1data = append(page1data, njn.carryOverData)
-				page1Size += sizeOfTuple(njn.carryOverData)
+			if len(chunk) == 0 {
+				return Tuple{}, nil
 			}
+			njn.chunk = chunk
+		}
 
-			/* Join created page with all pages of other table */
-			for _, t1 := range page1data {
-				for t2, err := inp2.next(); t2.data != nil; t2, err = inp2.next() {
-					if err != nil {
-						return Tuple{}, err
-					}
-
-					if t1.data[h1] == t2.data[h2] {
-						njn.res = append(njn.res, combineTuples(t1, t2))
-					}
-				}
-			}
+		t2, err := inp2.next()
+		if err != nil {
+			return Tuple{}, err
+		}
 
-			/* Reset input2 for next iteration */
-			err = inp2.reset()
+		if t2.data == nil { // r2 exhausted for this chunk, rewind it for the next one
+			err := inp2.reset()
 			if err != nil {
 				return Tuple{}, err
 			}
+			njn.mem.shrink(njn.chunkSize)
+			njn.chunk, njn.chunkIdx, njn.chunkSize = nil, 0, 0
+			continue
 		}
-	}
 
-	if njn.idx >= len(njn.res) {
-		return Tuple{}, nil
+		njn.inner, njn.chunkIdx = t2, 0
 	}
-
-	resTuple := njn.res[njn.idx]
-	njn.idx++
-	return resTuple, nil
 }
 
-func (njn *ChunkNestedJoinNode) close() error {
+// reads tuples of r1 until numberOfPages pages are filled or the memory budget runs out, the tuple that overflows is carried over to the next chunk
+func (njn *ChunkNestedJoinNode) nextChunk() ([]Tuple, error) {
+	chunk := []Tuple{}
+
+	for {
+		t1 := njn.carryOverData
+		njn.carryOverData = Tuple{}
+		if t1.data == nil {
+			var err error
+			t1, err = njn.inputs[0].next()
+			if err != nil {
+				return nil, err
+			}
+			if t1.data == nil {
+				return chunk, nil
+			}
+		}
+
+		if len(chunk) > 0 && njn.chunkSize+sizeOfTuple(t1) > PAGESIZE*njn.numberOfPages {
+			njn.carryOverData = t1
+			return chunk, nil
+		}
+
+		err := njn.mem.grow(sizeOfTuple(t1))
+		if err != nil {
+			if len(chunk) == 0 {
+				return nil, err
+			}
+			njn.carryOverData = t1
+			return chunk, nil
+		}
+
+		chunk = append(chunk, t1)
+		njn.chunkSize += sizeOfTuple(t1)
+	}
+}
+
+func (njn *ChunkNestedJoinNode) close() error {
+	njn.mem.releaseAll()
 	return nil
 }
 
@@ -638,6 +1100,8 @@ func (njn *ChunkNestedJoinNode) getInputs() ([]PlanNode, error) {
 }
 
 func (njn *ChunkNestedJoinNode) reset() error {
+	njn.mem.releaseAll()
+	njn.carryOverData, njn.chunk, njn.chunkSize, njn.chunkIdx, njn.inner = Tuple{}, nil, 0, 0, Tuple{}
 	return resetPlanNode(njn)
 }
 
@@ -645,135 +1109,467 @@ func (njn *ChunkNestedJoinNode) setInputs(inps []PlanNode) {
 	njn.inputs = inps
 }
 
-// rough size of a tuple, counting simply the key, value sizes and excluding the overhead of the Tuple structure itself
-func sizeOfTuple(t Tuple) int {
-	size := 0
-	for k, v := range t.data {
-		size += len(k) + int(unsafe.Sizeof(v))
-	}
-	return size
-}
-
 /* TODO: Index Nested Loop Join */
 
-/*** Hash Join Node ***/
+/*** Hash Join Node - Grace hash join, oversized partitions are recursively repartitioned with a new hash seed
+In hybrid mode the build table of partition 0 stays in memory while partitioning and s tuples hashing to it are probed right away instead of being spilled
+Matches are yielded as they are found: r is partitioned on the first call to next(), s and the spilled partitions are worked through only as far as the consumer pulls
+With a predicate, its equalities between r and s headers become the (multi-column) hash key and the remaining conjuncts are checked on every candidate pair
+With bloomFilter set, a bloom filter over r's keys is pushed down to s so s tuples that can't match are dropped before being partitioned
+Partitions are spilled into a directory of the node's own, handed out by the query's spill manager
+Build tables are reserved from the query's memory budget, a partition that can't be reserved is handled as if it didn't fit in memoryPages ***/
+
+const HASHJOINMEMORYPAGES = 1024 // default number of pages of a build partition held in memory at once
+const MAXREPARTITIONDEPTH = 3    // number of times an oversized partition is repartitioned before falling back to a block nested loop
+
+const (
+	HASHJOINPHASEBUILD = iota // partition r
+	HASHJOINPHASEPROBE        // partition s, probing the resident partition in hybrid mode
+	HASHJOINPHASEJOIN         // join spilled partitions one at a time
+	HASHJOINPHASEDONE
+)
 
 type HashJoinNode struct {
-	reqHeaders     []string
-	res            []Tuple
-	idx            int
-	inputs         []PlanNode
-	partitionCount int
-	headersInOrder [][]string // order of headers in partition
+	reqHeaders      []string // headers on which we are doing the join -> inputs[0] (build, r) -> reqHeaders[0] -> inputs[1] (probe, s) -> reqHeaders[1]
+	predicate       Expr     // arbitrary join condition, used instead of reqHeaders if set - must contain at least one equality between r and s
+	inputs          []PlanNode
+	partitionCount  int
+	memoryPages     int        // number of pages of a build partition that fit in memory, HASHJOINMEMORYPAGES if unset
+	headersInOrder  [][]string // order of headers in partition
+	hybrid          bool
+	bloomFilter     bool
+	bloomFilterBits int      // size of the bloom filter, BLOOMFILTERBITS if unset
+	resident        multiMap // build table of partition 0 in hybrid mode, nil once it has been spilled
+	residentSize    int
+	stats           hashJoinStats
+	qctx            *QueryContext
+	mem             memoryReservation
+
+	/* Join state */
+	spillDir    string      // directory holding the partitions, empty until r is partitioned and once the join is done
+	keysR       []string    // hash key headers of r
+	keysS       []string    // hash key headers of s, in the same order as keysR
+	residual    Expr        // conjuncts of predicate that aren't part of the hash key
+	bloom       *bloomProbe // bloom filter pushed down to s, nil if bloomFilter is unset
+	bloomInJoin bool        // s can't take the bloom filter, so s tuples are checked against it while being partitioned
+	phase       int
+	probeWriter *partitionWriter   // partitions s during HASHJOINPHASEPROBE
+	pending     []pendingPartition // spilled partitions yet to be joined, joined from the end
+	buildTable  multiMap           // build table of the partition (or block of it) being probed
+	buildSize   int                // bytes of buildTable reserved from the memory budget
+	probe       *partitionFileNode // s partition being streamed past buildTable
+	block       *partitionFileNode // r partition being joined block by block, nil unless in a block nested loop
+	blockCarry  Tuple              // first r tuple of the next block
+	blockPathS  string             // s partition streamed past every block
+	probeTuple  Tuple              // current s tuple
+	matches     []Tuple            // r tuples matching probeTuple
+	matchIdx    int                // next match to be yielded
+}
+
+type hashJoinStats struct {
+	repartitions       int // number of oversized partitions split again with a new seed
+	blockNestedLoops   int // number of partitions joined with a block nested loop since they could not be split further
+	partitionsInMemory int // number of partitions joined with an in-memory build table
+	tuplesSpilled      int // number of tuples of either side written to partition files
+	residentProbes     int // number of s tuples probed against the resident partition instead of being spilled
+	probeRowsSkipped   int // number of s tuples dropped by the bloom filter
+}
+
+type pendingPartition struct {
+	pathR  string
+	pathS  string
+	statsR partitionStats
+	depth  int
 }
 
 func (hjn *HashJoinNode) init() error {
+	if hjn.qctx == nil { // not run by an executor
+		hjn.qctx = NewQueryContext()
+	}
+	hjn.mem = memoryReservation{budget: hjn.qctx.memory}
 	return nil
 }
 
+func (hjn *HashJoinNode) setQueryContext(qctx *QueryContext) {
+	hjn.qctx = qctx
+}
+
 func (hjn *HashJoinNode) next() (Tuple, error) {
-	defer func() {
-		os.RemoveAll("./partitions")
-	}()
+	for {
+		/* Yield the remaining matches of the current s tuple */
+		if hjn.matchIdx < len(hjn.matches) {
+			tupleR := hjn.matches[hjn.matchIdx]
+			hjn.matchIdx++
+			if !keysEqual(tupleR, hjn.keysR, hjn.probeTuple, hjn.keysS) { // only hashed alike, e.g. "4" and "4.0" are different strings
+				continue
+			}
+			if hjn.residual != nil {
+				match, err := evalPredicate(hjn.residual, joinedRow{left: hjn.probeTuple, right: tupleR})
+				if err != nil {
+					return Tuple{}, err
+				}
+				if !match {
+					continue
+				}
+			}
+			return combineTuples(hjn.probeTuple, tupleR), nil
+		}
+		hjn.matches, hjn.matchIdx = nil, 0
+
+		switch hjn.phase {
+		case HASHJOINPHASEBUILD:
+			err := hjn.partitionBuildSide()
+			if err != nil {
+				return Tuple{}, err
+			}
+			hjn.phase = HASHJOINPHASEPROBE
 
-	if hjn.idx == 0 { // if join hasn't been performed - first perform complete join and then return elems one by one
-		err := os.Mkdir("./partitions", 0777)
+		case HASHJOINPHASEPROBE:
+			err := hjn.partitionNextProbeTuple()
+			if err != nil {
+				return Tuple{}, err
+			}
+
+		case HASHJOINPHASEJOIN:
+			err := hjn.joinNextProbeTuple()
+			if err != nil {
+				return Tuple{}, err
+			}
+
+		case HASHJOINPHASEDONE:
+			return Tuple{}, nil
+		}
+	}
+}
+
+// creates the partition directory and partitions all of r, in hybrid mode partition 0 is kept resident
+func (hjn *HashJoinNode) partitionBuildSide() error {
+	spillDir, err := hjn.qctx.spill.operatorDir("hashjoin")
+	if err != nil {
+		return err
+	}
+	hjn.spillDir = spillDir
+
+	if hjn.predicate != nil {
+		hjn.keysR, hjn.keysS, hjn.residual = splitEquiConjuncts(hjn.predicate, hjn.headersInOrder[0], hjn.headersInOrder[1])
+		if len(hjn.keysR) == 0 {
+			return fmt.Errorf("hash join predicate has no equality between the two inputs")
+		}
+	} else {
+		hjn.keysR, hjn.keysS = []string{hjn.reqHeaders[0]}, []string{hjn.reqHeaders[1]}
+	}
+
+	for i := range hjn.keysR {
+		if searchStringInList(hjn.keysR[i], hjn.headersInOrder[0]) == -1 || searchStringInList(hjn.keysS[i], hjn.headersInOrder[1]) == -1 {
+			return fmt.Errorf("required header not in headersInOrderList")
+		}
+	}
+
+	var keepR func(partitionIdx int, key string, tuple Tuple) (bool, error)
+	if hjn.hybrid {
+		hjn.resident, hjn.residentSize = multiMap{}, 0
+		keepR = hjn.keepResidentTuple
+	}
+	if hjn.bloomFilter {
+		bloomFilter := newBloomFilter(hjn.bloomFilterBits)
+		err := hjn.mem.grow(len(bloomFilter.bits) * 8)
 		if err != nil {
-			return Tuple{}, err
+			return err
+		}
+		hjn.bloom = &bloomProbe{filter: bloomFilter, keyHeaders: hjn.keysS}
+		keepR = func(partitionIdx int, key string, tuple Tuple) (bool, error) {
+			hjn.bloom.filter.add(key)
+			if hjn.hybrid {
+				return hjn.keepResidentTuple(partitionIdx, key, tuple)
+			}
+			return false, nil
+		}
+	}
+
+	rStats, err := hjn.createPartitions(hjn.inputs[0], hjn.keysR, hjn.partitionPath("r"), hjn.headersInOrder[0], 0, keepR)
+	if err != nil {
+		return err
+	}
+
+	/* Partitions are joined from the end of pending, queue them so partition 0 is joined first */
+	for i := hjn.partitionCount - 1; i >= 0; i-- {
+		if i == 0 && hjn.resident != nil { // joined while partitioning s
+			hjn.stats.partitionsInMemory++
+			continue
 		}
-		err = os.Mkdir("./partitions/r", 0777)
+		hjn.pending = append(hjn.pending, pendingPartition{pathR: fmt.Sprintf("%s%d", hjn.partitionPath("r"), i), pathS: fmt.Sprintf("%s%d", hjn.partitionPath("s"), i), statsR: rStats[i]})
+	}
+
+	if hjn.bloom != nil { // r is complete, so s can now be filtered on its keys
+		hjn.bloomInJoin = !pushDownBloomProbe(hjn.inputs[1], hjn.bloom)
+	}
+
+	hjn.probeWriter = hjn.newPartitionWriter(hjn.keysS, hjn.partitionPath("s"), hjn.headersInOrder[1], 0)
+	return nil
+}
+
+// routes the next s tuple to its partition, s tuples of the resident partition are probed right away instead of being spilled
+func (hjn *HashJoinNode) partitionNextProbeTuple() error {
+	tupleS, err := hjn.inputs[1].next()
+	if err != nil {
+		return err
+	}
+
+	if tupleS.data == nil {
+		err := hjn.probeWriter.flush()
 		if err != nil {
-			return Tuple{}, err
+			return err
 		}
-		err = os.Mkdir("./partitions/s", 0777)
+		hjn.probeWriter = nil
+		hjn.phase = HASHJOINPHASEJOIN
+		if hjn.resident != nil { // partition 0 is fully joined
+			hjn.mem.shrink(hjn.residentSize)
+			hjn.resident, hjn.residentSize = nil, 0
+		}
+		if hjn.bloom != nil {
+			hjn.stats.probeRowsSkipped = hjn.bloom.skipped
+		}
+		return nil
+	}
+
+	if hjn.bloomInJoin && !hjn.bloom.mayMatch(tupleS) {
+		return nil
+	}
+	if hasNullKey(tupleS, hjn.keysS) { // can't match anything
+		return nil
+	}
+
+	partitionIdx, key := hjn.probeWriter.route(tupleS)
+	if partitionIdx == 0 && hjn.resident != nil {
+		hjn.probeTuple, hjn.matches = tupleS, hjn.resident.lookup(key)
+		hjn.stats.residentProbes++
+		return nil
+	}
+
+	return hjn.probeWriter.spill(partitionIdx, tupleS)
+}
+
+// streams the next s tuple of the partition being joined past its build table, moving on to the next block or partition once s is exhausted
+func (hjn *HashJoinNode) joinNextProbeTuple() error {
+	if hjn.probe != nil {
+		tupleS, err := hjn.probe.next()
 		if err != nil {
-			return Tuple{}, err
+			return err
+		}
+		if tupleS.data != nil {
+			hjn.probeTuple, hjn.matches = tupleS, hjn.buildTable.lookup(joinKey(tupleS, hjn.keysS))
+			return nil
 		}
 
-		/* Create partitions */
-		err = hjn.createPartitions(hjn.inputs[0], hjn.reqHeaders[0], "./partitions/r/r", hjn.headersInOrder[0])
+		err = hjn.probe.close()
 		if err != nil {
-			return Tuple{}, err
+			return err
 		}
+		hjn.mem.shrink(hjn.buildSize)
+		hjn.probe, hjn.buildTable, hjn.buildSize = nil, nil, 0
+
+		if hjn.block != nil {
+			return hjn.nextBlock()
+		}
+		return nil
+	}
+
+	if len(hjn.pending) == 0 {
+		hjn.phase = HASHJOINPHASEDONE
+		return hjn.releaseSpillDir()
+	}
+
+	p := hjn.pending[len(hjn.pending)-1]
+	hjn.pending = hjn.pending[:len(hjn.pending)-1]
+	return hjn.startPartition(p)
+}
 
-		err = hjn.createPartitions(hjn.inputs[1], hjn.reqHeaders[1], "./partitions/s/s", hjn.headersInOrder[1])
+// prepares to join an r partition with its s partition - in memory if r fits, otherwise by repartitioning both with the next seed, or with a block nested loop once r can't be split any further
+func (hjn *HashJoinNode) startPartition(p pendingPartition) error {
+	if p.statsR.rows == 0 {
+		return nil
+	}
+
+	if p.statsR.size <= hjn.memoryLimit() && hjn.mem.grow(p.statsR.size) == nil {
+		buildTable, err := hjn.buildPartition(p.pathR)
 		if err != nil {
-			return Tuple{}, err
+			return err
 		}
+		hjn.stats.partitionsInMemory++
+		hjn.buildSize = p.statsR.size
+		return hjn.startProbe(p.pathS, buildTable)
+	}
 
-		joinHeaderIdxR, joinHeaderIdxS := searchStringInList(hjn.reqHeaders[0], hjn.headersInOrder[0]), searchStringInList(hjn.reqHeaders[1], hjn.headersInOrder[1])
-		if joinHeaderIdxR == -1 || joinHeaderIdxS == -1 {
-			return Tuple{}, fmt.Errorf("required header not in headersInOrderList")
+	if !p.statsR.multiKey || p.depth >= MAXREPARTITIONDEPTH { // a single key can never be split by rehashing
+		hjn.stats.blockNestedLoops++
+		hjn.block, hjn.blockPathS = &partitionFileNode{path: p.pathR, headers: hjn.headersInOrder[0]}, p.pathS
+		err := hjn.block.init()
+		if err != nil {
+			return err
+		}
+		hjn.blockCarry, err = hjn.block.next()
+		if err != nil {
+			return err
 		}
+		return hjn.nextBlock()
+	}
 
-		/* Bring r's partitions into memory + create fine-grained hash map for it -> stream s corresponding partition into memory, match it with r's partition */
-		hashMapR := map[string]Tuple{}
+	/* Repartition both sides with a new seed, so keys that collided at this depth spread out */
+	hjn.stats.repartitions++
+	subPrefixR, subPrefixS := fmt.Sprintf("%s_", p.pathR), fmt.Sprintf("%s_", p.pathS)
+	subStatsR, err := hjn.createPartitions(&partitionFileNode{path: p.pathR, headers: hjn.headersInOrder[0]}, hjn.keysR, subPrefixR, hjn.headersInOrder[0], uint32(p.depth+1), nil)
+	if err != nil {
+		return err
+	}
+	_, err = hjn.createPartitions(&partitionFileNode{path: p.pathS, headers: hjn.headersInOrder[1]}, hjn.keysS, subPrefixS, hjn.headersInOrder[1], uint32(p.depth+1), nil)
+	if err != nil {
+		return err
+	}
+	err = hjn.qctx.spill.removeFile(hjn.spillDir, p.pathR)
+	if err != nil {
+		return err
+	}
+	err = hjn.qctx.spill.removeFile(hjn.spillDir, p.pathS)
+	if err != nil {
+		return err
+	}
 
-		for i := 0; i < hjn.partitionCount; i++ {
-			fr, err := os.Open(fmt.Sprintf("./partitions/r/r%s", strconv.Itoa(i)))
-			if err != nil {
-				if os.IsNotExist(err) {
-					continue
-				}
-				return Tuple{}, err
-			}
-			defer fr.Close()
+	for i := hjn.partitionCount - 1; i >= 0; i-- {
+		hjn.pending = append(hjn.pending, pendingPartition{pathR: fmt.Sprintf("%s%d", subPrefixR, i), pathS: fmt.Sprintf("%s%d", subPrefixS, i), statsR: subStatsR[i], depth: p.depth + 1})
+	}
 
-			scR := bufio.NewScanner(fr)
-			for scR.Scan() {
-				recordAsList := strings.Split(scR.Text(), ",")
-				tuple := stringListToTuple(recordAsList, hjn.headersInOrder[0])
-				hashKey := tuple.data[hjn.reqHeaders[0]].(string)
-				hashMapR[hashKey] = tuple
-			}
-			if err := scR.Err(); err != nil {
-				fmt.Fprintln(os.Stderr, "error reading r partition", err)
-			}
+	return nil
+}
 
-			fs, err := os.Open(fmt.Sprintf("./partitions/s/s%s", strconv.Itoa(i)))
-			if err != nil {
-				if os.IsNotExist(err) {
-					continue
-				}
-				return Tuple{}, err
-			}
-			defer fs.Close()
+func (hjn *HashJoinNode) startProbe(pathS string, buildTable multiMap) error {
+	hjn.buildTable, hjn.probe = buildTable, &partitionFileNode{path: pathS, headers: hjn.headersInOrder[1]}
+	return hjn.probe.init()
+}
 
-			scS := bufio.NewScanner(fs)
-			for scS.Scan() {
-				recordAsList := strings.Split(scS.Text(), ",")
-				tupleS := stringListToTuple(recordAsList, hjn.headersInOrder[1])
-				hashKey := tupleS.data[hjn.reqHeaders[0]].(string)
-				tupleR, exists := hashMapR[hashKey]
-				if exists {
-					hjn.res = append(hjn.res, combineTuples(tupleS, tupleR))
-				}
-			}
-			if err := scS.Err(); err != nil {
-				fmt.Fprintln(os.Stderr, "error reading s partition", err)
+// reads an r partition from disk into a multimap keyed on the r join header, a missing partition results in an empty table
+func (hjn *HashJoinNode) buildPartition(path string) (multiMap, error) {
+	buildTable := multiMap{}
+
+	scR := &partitionFileNode{path: path, headers: hjn.headersInOrder[0]}
+	err := scR.init()
+	if err != nil {
+		return nil, err
+	}
+	defer scR.close()
+
+	for {
+		tupleR, err := scR.next()
+		if err != nil {
+			return nil, err
+		}
+		if tupleR.data == nil {
+			break
+		}
+		buildTable.insert(joinKey(tupleR, hjn.keysR), tupleR)
+	}
+
+	return buildTable, nil
+}
+
+// keeps an r tuple of partition 0 in the resident build table, once the table outgrows memory it is spilled and partition 0 is handled like every other partition
+func (hjn *HashJoinNode) keepResidentTuple(partitionIdx int, key string, tuple Tuple) (bool, error) {
+	if partitionIdx != 0 || hjn.resident == nil {
+		return false, nil
+	}
+
+	if hjn.residentSize+sizeOfTuple(tuple) > hjn.memoryLimit() || hjn.mem.grow(sizeOfTuple(tuple)) != nil {
+		err := hjn.flushPartitionToDisk(hjn.resident.tuples(), fmt.Sprintf("%s0", hjn.partitionPath("r")), hjn.headersInOrder[0])
+		hjn.mem.shrink(hjn.residentSize)
+		hjn.resident, hjn.residentSize = nil, 0
+		return false, err
+	}
+
+	hjn.resident.insert(key, tuple)
+	hjn.residentSize += sizeOfTuple(tuple)
+	return true, nil
+}
+
+// brings in as much of the r partition of a block nested loop as fits in memory and starts streaming the whole s partition past it
+func (hjn *HashJoinNode) nextBlock() error {
+	if hjn.blockCarry.data == nil {
+		err := hjn.block.close()
+		hjn.block = nil
+		return err
+	}
+
+	buildTable, size := multiMap{}, 0
+	for hjn.blockCarry.data != nil && (size == 0 || size+sizeOfTuple(hjn.blockCarry) <= hjn.memoryLimit()) {
+		err := hjn.mem.grow(sizeOfTuple(hjn.blockCarry))
+		if err != nil {
+			if size == 0 {
+				return err
 			}
+			break
 		}
+		buildTable.insert(joinKey(hjn.blockCarry, hjn.keysR), hjn.blockCarry)
+		size += sizeOfTuple(hjn.blockCarry)
 
+		hjn.blockCarry, err = hjn.block.next()
+		if err != nil {
+			return err
+		}
 	}
 
-	if hjn.idx >= len(hjn.res) {
-		return Tuple{}, nil
+	hjn.buildSize = size
+	return hjn.startProbe(hjn.blockPathS, buildTable)
+}
+
+func (hjn *HashJoinNode) memoryLimit() int {
+	if hjn.memoryPages <= 0 {
+		return HASHJOINMEMORYPAGES * PAGESIZE
 	}
+	return hjn.memoryPages * PAGESIZE
+}
 
-	resTuple := hjn.res[hjn.idx]
-	hjn.idx++
-	return resTuple, nil
+func (hjn *HashJoinNode) close() error { // the consumer may stop pulling midway, so open partition files are closed and the partitions removed here
+	if hjn.probe != nil {
+		hjn.probe.close()
+		hjn.probe = nil
+	}
+	if hjn.block != nil {
+		hjn.block.close()
+		hjn.block = nil
+	}
+	hjn.mem.releaseAll()
+	return hjn.releaseSpillDir()
 }
 
-func (hjn *HashJoinNode) close() error {
-	return nil
+// prefix of the partition files of one side of the join, "r" or "s"
+func (hjn *HashJoinNode) partitionPath(side string) string {
+	return filepath.Join(hjn.spillDir, side)
+}
+
+func (hjn *HashJoinNode) releaseSpillDir() error {
+	if hjn.spillDir == "" {
+		return nil
+	}
+	err := hjn.qctx.spill.releaseDir(hjn.spillDir)
+	hjn.spillDir = ""
+	return err
 }
 
 func (hjn *HashJoinNode) getInputs() ([]PlanNode, error) {
 	return hjn.inputs, nil
 }
 
-func (hjn *HashJoinNode) reset() error {
+func (hjn *HashJoinNode) reset() error { // the join is redone from scratch, r may differ after its inputs are reset
+	err := hjn.close()
+	if err != nil {
+		return err
+	}
+
+	if hjn.bloom != nil { // s must not be filtered on the keys of the previous r
+		pushDownBloomProbe(hjn.inputs[1], nil)
+	}
+	hjn.keysR, hjn.keysS, hjn.residual, hjn.bloom, hjn.bloomInJoin = nil, nil, nil, nil, false
+	hjn.phase, hjn.probeWriter, hjn.pending = HASHJOINPHASEBUILD, nil, nil
+	hjn.resident, hjn.residentSize, hjn.buildTable, hjn.buildSize = nil, 0, nil, 0
+	hjn.blockCarry, hjn.blockPathS, hjn.probeTuple, hjn.matches, hjn.matchIdx = Tuple{}, "", Tuple{}, nil, 0
 	return resetPlanNode(hjn)
 }
 
@@ -781,127 +1577,307 @@ func (hjn *HashJoinNode) setInputs(inps []PlanNode) {
 	hjn.inputs = inps
 }
 
-func (hjn *HashJoinNode) createPartitions(inp PlanNode, header string, pathPrefix string, headersInOrder []string) error {
-	type OpBuffer struct {
-		tuples []Tuple
-		size   int
+// partitions all tuples of inp into partitionCount files named pathPrefix<idx> using a hash of the key headers seeded with seed, returns stats per partition
+// tuples for which keep returns true are consumed by the caller and not written to disk
+func (hjn *HashJoinNode) createPartitions(inp PlanNode, keyHeaders []string, pathPrefix string, headersInOrder []string, seed uint32, keep func(partitionIdx int, key string, tuple Tuple) (bool, error)) ([]partitionStats, error) {
+	if pfn, ok := inp.(*partitionFileNode); ok { // partition files are scanned here rather than by the executor
+		err := pfn.init()
+		if err != nil {
+			return nil, err
+		}
+		defer pfn.close()
 	}
 
-	carryOverRecord := Tuple{}
-	opBuffers := map[int]*OpBuffer{}
-
+	pw := hjn.newPartitionWriter(keyHeaders, pathPrefix, headersInOrder, seed)
 	for {
-		/* Initialize input buffers */
-		inpBuffer, inpBufferSize := []Tuple{}, 0
-
-		if carryOverRecord.data != nil {
-			inpBuffer = append(inpBuffer, carryOverRecord)
-			inpBufferSize += sizeOfTuple(carryOverRecord)
+		tuple, err := inp.next()
+		if err != nil {
+			return nil, err
+		}
+		if tuple.data == nil {
+			break
+		}
+		if hasNullKey(tuple, keyHeaders) { // can't match anything
+			continue
 		}
 
-		/* Fill up input buffer till PAGESIZE */
-		for {
-			record, err := inp.next()
+		partitionIdx, key := pw.route(tuple)
+		if keep != nil {
+			kept, err := keep(partitionIdx, key, tuple)
 			if err != nil {
-				return err
+				return nil, err
 			}
-
-			if record.data == nil || inpBufferSize+sizeOfTuple(record) > PAGESIZE {
-				carryOverRecord = record
-				break
+			if kept {
+				continue
 			}
+		}
 
-			if record.data != nil {
-				inpBuffer = append(inpBuffer, record)
-				inpBufferSize += sizeOfTuple(record)
-			}
+		err = pw.spill(partitionIdx, tuple)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		/* Partition input buffer records into correct output buffers */
-		for _, tuple := range inpBuffer {
-			int64Val, err := strconv.ParseInt(tuple.data[header].(string), 10, 32) // assuming tuples vals always returning as string for now
-			intVal := int(int64Val)
-			if err != nil {
-				return err
-			}
+	err := pw.flush()
+	if err != nil {
+		return nil, err
+	}
 
-			partitionIdx := intVal % hjn.partitionCount // assuming we always have int values which can be modulo-ed
-			_, exists := opBuffers[partitionIdx]        // find correct output buffer
-			if !exists {
-				opBuffers[partitionIdx] = &OpBuffer{}
-			}
-			opBuffer := opBuffers[partitionIdx]
+	return pw.stats, nil
+}
 
-			if opBuffer.size+sizeOfTuple(tuple) > PAGESIZE { // flush output buffer to disk if filled up
+func (hjn *HashJoinNode) flushPartitionToDisk(tuples []Tuple, path string, headersInOrder []string) error {
+	if len(tuples) == 0 {
+		return nil
+	}
 
-				err := hjn.flushPartitionToDisk(opBuffer.tuples, fmt.Sprintf("%s%s", pathPrefix, strconv.Itoa(partitionIdx)), headersInOrder)
-				if err != nil {
-					return err
-				}
+	/* Every flush appends one frame: the length of a gob encoded page of tuples followed by the page, values keep their types */
+	page := make([]encodedTuple, len(tuples))
+	for i, tuple := range tuples {
+		data := make(map[string]interface{}, len(headersInOrder))
+		for _, header := range headersInOrder {
+			data[header] = tuple.data[header]
+		}
+		page[i] = encodeTuple(Tuple{data: data})
+	}
 
-				opBuffer.tuples = []Tuple{}
-				opBuffer.size = 0
-			}
+	buf := new(bytes.Buffer)
+	buf.Write(make([]byte, 4)) // length, filled in below
+	if err := gob.NewEncoder(buf).Encode(page); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(buf.Bytes()[:4], uint32(buf.Len()-4))
 
-			opBuffer.tuples = append(opBuffer.tuples, tuple)
-			opBuffer.size += sizeOfTuple(tuple)
-		}
+	err := hjn.qctx.spill.appendFile(hjn.spillDir, path, buf.Bytes())
+	if err != nil {
+		return err
+	}
 
-		/* Checking if next iteration to be performed i.e. if all records already partitioned*/
-		if carryOverRecord.data == nil {
-			record, err := inp.next()
-			if err != nil {
-				return err
-			}
-			if record.data == nil {
-				break
-			}
-			carryOverRecord = record
-		}
+	hjn.stats.tuplesSpilled += len(tuples)
+	return nil
+}
 
+/*** Partition Writer - Buffers tuples per partition a page at a time and flushes full pages to the partition files ***/
+
+type partitionWriter struct {
+	hjn            *HashJoinNode
+	keyHeaders     []string
+	pathPrefix     string
+	headersInOrder []string
+	seed           uint32
+	buffers        map[int]*partitionBuffer
+	stats          []partitionStats
+}
+
+type partitionBuffer struct {
+	tuples []Tuple
+	size   int
+}
+
+func (hjn *HashJoinNode) newPartitionWriter(keyHeaders []string, pathPrefix string, headersInOrder []string, seed uint32) *partitionWriter {
+	return &partitionWriter{hjn: hjn, keyHeaders: keyHeaders, pathPrefix: pathPrefix, headersInOrder: headersInOrder, seed: seed, buffers: map[int]*partitionBuffer{}, stats: make([]partitionStats, hjn.partitionCount)}
+}
+
+// returns the partition and join key of a tuple, counting it towards the partition's stats
+func (pw *partitionWriter) route(tuple Tuple) (int, string) {
+	key := joinKey(tuple, pw.keyHeaders)
+	partitionIdx := partitionOf(key, pw.seed, pw.hjn.partitionCount)
+	pw.stats[partitionIdx].add(key, sizeOfTuple(tuple))
+	return partitionIdx, key
+}
+
+func (pw *partitionWriter) spill(partitionIdx int, tuple Tuple) error {
+	_, exists := pw.buffers[partitionIdx] // find correct output buffer
+	if !exists {
+		pw.buffers[partitionIdx] = &partitionBuffer{}
 	}
+	buffer := pw.buffers[partitionIdx]
 
-	/* Flushing all output buffers that have not been */
-	for partitionIdx, opBuffer := range opBuffers {
-		err := hjn.flushPartitionToDisk(opBuffer.tuples, fmt.Sprintf("%s%s", pathPrefix, strconv.Itoa(partitionIdx)), headersInOrder)
+	if buffer.size+sizeOfTuple(tuple) > PAGESIZE { // flush output buffer to disk if filled up
+		err := pw.hjn.flushPartitionToDisk(buffer.tuples, fmt.Sprintf("%s%d", pw.pathPrefix, partitionIdx), pw.headersInOrder)
 		if err != nil {
 			return err
 		}
+
+		buffer.tuples = []Tuple{}
+		buffer.size = 0
 	}
 
+	buffer.tuples = append(buffer.tuples, tuple)
+	buffer.size += sizeOfTuple(tuple)
 	return nil
 }
 
-func (hjn *HashJoinNode) flushPartitionToDisk(tuples []Tuple, path string, headersInOrder []string) error {
-	if len(tuples) == 0 {
-		return nil
+// flushes all output buffers that have not been
+func (pw *partitionWriter) flush() error {
+	for partitionIdx, buffer := range pw.buffers {
+		err := pw.hjn.flushPartitionToDisk(buffer.tuples, fmt.Sprintf("%s%d", pw.pathPrefix, partitionIdx), pw.headersInOrder)
+		if err != nil {
+			return err
+		}
+		delete(pw.buffers, partitionIdx)
+	}
+	return nil
+}
+
+// size, row count and whether more than one distinct key landed in a partition - a partition with a single key can't be split by repartitioning
+type partitionStats struct {
+	size     int
+	rows     int
+	firstKey string
+	multiKey bool
+}
+
+func (ps *partitionStats) add(key string, size int) {
+	if ps.rows == 0 {
+		ps.firstKey = key
+	} else if key != ps.firstKey {
+		ps.multiKey = true
 	}
+	ps.rows++
+	ps.size += size
+}
+
+// index of the partition a key hashes to, a different seed gives an independent distribution of keys
+func partitionOf(key string, seed uint32, partitionCount int) int {
+	h := fnv.New32a()
+	binary.Write(h, binary.BigEndian, seed)
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(partitionCount))
+}
+
+// hash key of a tuple over one or more headers
+// NULL keys never equal anything, so such tuples take no part in an equi-join
+func hasNullKey(t Tuple, keyHeaders []string) bool {
+	for _, header := range keyHeaders {
+		if t.data[header] == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// values that valuesEqual finds equal get the same key, but tuples with the same key may still differ - matches are confirmed with keysEqual
+func joinKey(t Tuple, keyHeaders []string) string {
+	if len(keyHeaders) == 1 {
+		return joinKeyValue(t.data[keyHeaders[0]])
+	}
+	values := make([]string, len(keyHeaders))
+	for i, header := range keyHeaders {
+		values[i] = joinKeyValue(t.data[header])
+	}
+	return strings.Join(values, "\x1f")
+}
+
+// numbers, timestamps and bools, and strings that parse as one, are keyed by their value so 4, int64(4) and "4.0" hash alike
+// bools are keyed like the numbers 1 and 0, which parse as them
+func joinKeyValue(v interface{}) string {
+	if n, isNumber := toNumber(v); isNumber {
+		return n.key()
+	}
+	if s, isString := v.(string); isString && s != "" {
+		if t, err := parseValue(s, TYPETIMESTAMP); err == nil {
+			v = t
+		} else if b, err := parseValue(s, TYPEBOOL); err == nil {
+			v = b
+		}
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case bool:
+		return strconv.FormatInt(boolToInt(t), 10)
+	}
+	return fmt.Sprint(v)
+}
+
+// whether the keys of two tuples are equal the way FilterNode and the nested loop joins compare them
+func keysEqual(t1 Tuple, keyHeaders1 []string, t2 Tuple, keyHeaders2 []string) bool {
+	for i := range keyHeaders1 {
+		if eq, _ := valuesEqual(t1.data[keyHeaders1[i]], t2.data[keyHeaders2[i]]); !eq {
+			return false
+		}
+	}
+	return true
+}
 
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+/*** Partition File Node - Scans a spilled hash join partition, a missing partition file is treated as empty ***/
+
+type partitionFileNode struct {
+	path    string
+	headers []string // order of headers in partition
+	file    *os.File
+	reader  *bufio.Reader
+	page    []encodedTuple // tuples of the frame being read
+	inputs  []PlanNode
+}
+
+func (pfn *partitionFileNode) init() error {
+	file, err := os.Open(pfn.path)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		return err
 	}
-	defer f.Close()
 
-	buf := new(bytes.Buffer)
-	for _, tuple := range tuples {
-		for i, header := range headersInOrder {
-			v := tuple.data[header]
-			vAsString := v.(string) //assuming tuple data value always of type string, for now
-			buf.WriteString(vAsString)
-			if i < len(tuple.data)-1 {
-				buf.WriteString(",")
-			}
+	pfn.file = file
+	pfn.reader = bufio.NewReader(file)
+	return nil
+}
+
+func (pfn *partitionFileNode) next() (Tuple, error) {
+	if pfn.reader == nil { // nothing was spilled to this partition
+		return Tuple{}, nil
+	}
+
+	for len(pfn.page) == 0 {
+		length := make([]byte, 4)
+		_, err := io.ReadFull(pfn.reader, length)
+		if err == io.EOF {
+			return Tuple{}, nil // EOF
+		}
+		if err != nil {
+			return Tuple{}, fmt.Errorf("error reading partition %s: %w", pfn.path, err)
+		}
+
+		frame := make([]byte, binary.BigEndian.Uint32(length))
+		if _, err := io.ReadFull(pfn.reader, frame); err != nil {
+			return Tuple{}, fmt.Errorf("error reading partition %s: %w", pfn.path, err)
 		}
-		buf.WriteString("\r\n")
+		if err := gob.NewDecoder(bytes.NewReader(frame)).Decode(&pfn.page); err != nil {
+			return Tuple{}, fmt.Errorf("error decoding partition %s: %w", pfn.path, err)
+		}
+	}
+
+	tuple := pfn.page[0].decode()
+	pfn.page = pfn.page[1:]
+	return tuple, nil
+}
+
+func (pfn *partitionFileNode) close() error {
+	if pfn.file == nil {
+		return nil
 	}
+	err := pfn.file.Close()
+	pfn.file, pfn.reader, pfn.page = nil, nil, nil
+	return err
+}
+
+func (pfn *partitionFileNode) getInputs() ([]PlanNode, error) {
+	return pfn.inputs, nil
+}
 
-	_, err = f.Write(buf.Bytes())
+func (pfn *partitionFileNode) reset() error {
+	err := pfn.close()
 	if err != nil {
 		return err
 	}
+	return pfn.init()
+}
 
-	return nil
+func (pfn *partitionFileNode) setInputs(inps []PlanNode) {
+	pfn.inputs = inps
 }
 
 func searchStringInList(s string, l []string) int {
@@ -913,11 +1889,23 @@ func searchStringInList(s string, l []string) int {
 	return -1
 }
 
-func stringListToTuple(values []string, headers []string) Tuple {
-	tuple := Tuple{data: map[string]interface{}{}}
-	for i, header := range headers {
-		value := values[i]
-		tuple.data[header] = value
+/*** Multimap - build table of a hash join ***/
+
+// multiMap maps a join key to every build tuple carrying it, so duplicate keys on either side of the join all produce matches
+type multiMap map[string][]Tuple
+
+func (m multiMap) insert(key string, t Tuple) {
+	m[key] = append(m[key], t)
+}
+
+func (m multiMap) lookup(key string) []Tuple {
+	return m[key]
+}
+
+func (m multiMap) tuples() []Tuple {
+	tuples := []Tuple{}
+	for _, ts := range m {
+		tuples = append(tuples, ts...)
 	}
-	return tuple
+	return tuples
 }
@@ -0,0 +1,184 @@
+package main
+
+import "fmt"
+
+/*** Optimizer - rewrites a plan before it is initialised, every rule walks the whole plan and returns its root
+Rules only push work down into the nodes that can do it earlier, the nodes above stay in place so results never change ***/
+
+type optimizerRule func(pn PlanNode) (PlanNode, error)
+
+var OPTIMIZERRULES = []optimizerRule{pushDownScanFilters, pruneColumns}
+
+func OptimizePlan(pn PlanNode) (PlanNode, error) {
+	for _, rule := range OPTIMIZERRULES {
+		var err error
+		pn, err = rule(pn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pn, nil
+}
+
+// calls visit on every node of the plan, parents before their inputs
+func walkPlan(pn PlanNode, visit func(pn PlanNode) error) error {
+	if pn == nil {
+		return nil
+	}
+	err := visit(pn)
+	if err != nil {
+		return err
+	}
+
+	pnChildren, err := pn.getInputs()
+	if err != nil {
+		return err
+	}
+	for _, pnChild := range pnChildren {
+		err := walkPlan(pnChild, visit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type scanFilter struct { // header = value, evaluated like FilterNode does
+	header string
+	value  string
+}
+
+func (sf scanFilter) String() string {
+	return fmt.Sprintf("%v = %v", sf.header, sf.value)
+}
+
+// scans that can evaluate the equality filters above them, skipping partitions and rows they reject before building any tuple
+type scanFilterSink interface {
+	addScanFilter(sf scanFilter)
+}
+
+func addScanFilter(filters []scanFilter, sf scanFilter) []scanFilter {
+	for _, existing := range filters {
+		if existing == sf { // the plan was optimised before
+			return filters
+		}
+	}
+	return append(filters, sf)
+}
+
+// hands the predicate of every equality filter to the scan under it, through other filters and projections
+// the filter stays in place, a scan evaluates only the predicates on its own columns
+func pushDownScanFilters(pn PlanNode) (PlanNode, error) {
+	err := walkPlan(pn, func(pn PlanNode) error {
+		fn, ok := pn.(*FilterNode)
+		if !ok || fn.operator != "=" || fn.header == "" {
+			return nil
+		}
+
+		inp := fn.inputs[0]
+		for {
+			switch node := inp.(type) {
+			case *FilterNode:
+				inp = node.inputs[0]
+				continue
+			case *ProjectionNode:
+				inp = node.inputs[0]
+				continue
+			case scanFilterSink:
+				node.addScanFilter(scanFilter{header: fn.header, value: fn.cmpValue})
+			}
+			return nil
+		}
+	})
+	return pn, err
+}
+
+// scans that can leave out the columns no node above them uses
+type columnPruningSink interface {
+	setRequiredColumns(columns []string)
+}
+
+// works out which columns every node's output is used for from the projections and aggregations above it, and hands scans the columns they have to produce
+func pruneColumns(pn PlanNode) (PlanNode, error) {
+	return pn, pushDownRequiredColumns(pn, nil)
+}
+
+// required is nil if every column of pn's output may be used, as it is for the root and below nodes the rule doesn't know
+func pushDownRequiredColumns(pn PlanNode, required []string) error {
+	if pn == nil {
+		return nil
+	}
+
+	var inputRequired []string
+	switch node := pn.(type) {
+	case columnPruningSink:
+		if required != nil {
+			node.setRequiredColumns(required)
+		}
+	case *ProjectionNode:
+		inputRequired = node.reqHeaders
+	case *FilterNode:
+		inputRequired = withColumns(required, node.header)
+		if node.bloom != nil {
+			inputRequired = withColumns(inputRequired, node.bloom.keyHeaders...)
+		}
+	case *AvgNode:
+		inputRequired = []string{node.header}
+		if node.mode == AGGFINAL {
+			inputRequired = []string{"sum", "count"}
+		}
+	case *LimitNode, *MaterializeNode, *ExchangeNode: // tuples pass through unchanged
+		inputRequired = required
+	case *NaiveNestedJoinNode:
+		inputRequired = joinColumns(required, node.headers, node.predicate)
+	case *ChunkNestedJoinNode:
+		inputRequired = joinColumns(required, node.headers, node.predicate)
+	case *HashJoinNode:
+		inputRequired = joinColumns(required, node.reqHeaders, node.predicate)
+		for _, headers := range node.headersInOrder { // written to the partitions
+			inputRequired = withColumns(inputRequired, headers...)
+		}
+	}
+
+	pnChildren, err := pn.getInputs()
+	if err != nil {
+		return err
+	}
+	for _, pnChild := range pnChildren {
+		err := pushDownRequiredColumns(pnChild, inputRequired)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columns both inputs of a join are asked for - each input ignores those it doesn't have
+func joinColumns(required []string, headers []string, predicate Expr) []string {
+	if predicate == nil {
+		return withColumns(required, headers...)
+	}
+	predicateColumns, ok := exprColumns(predicate)
+	if !ok {
+		return nil
+	}
+	return withColumns(required, predicateColumns...)
+}
+
+// adds columns to a list of required columns, a nil list already holds every column
+func withColumns(required []string, columns ...string) []string {
+	if required == nil {
+		return nil
+	}
+	res := append([]string{}, required...)
+	for _, column := range columns {
+		if column != "" && searchStringInList(column, res) == -1 {
+			res = append(res, column)
+		}
+	}
+	return res
+}
+
+func isRequiredColumn(column string, required []string) bool {
+	return required == nil || searchStringInList(column, required) != -1
+}
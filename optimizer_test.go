@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnPruning(t *testing.T) {
+	movies, ratings := syntheticMovieRatings(40, 300, 60)
+	movieScans, ratingScans := scanNodes(t, movies), scanNodes(t, ratings)
+
+	plan := func(movieScan PlanNode, ratingScan PlanNode) PlanNode {
+		return &ProjectionNode{reqHeaders: []string{"title", "userId"}, inputs: []PlanNode{
+			&NaiveNestedJoinNode{headers: []string{"movieId", "movieId"}, inputs: []PlanNode{
+				&FilterNode{header: "title", operator: "=", cmpValue: "t7", inputs: []PlanNode{movieScan}},
+				ratingScan,
+			}},
+		}}
+	}
+	qe := QueryExecutor{}
+	expected, err := qe.ExecutePlan(&QueryDescriptor{planNode: plan(movieScans["table"](), ratingScans["table"]())})
+	require.NoError(t, err)
+	require.Len(t, expected, 5)
+
+	for _, scanType := range []string{"csv", "ycfile"} {
+		t.Run(scanType, func(t *testing.T) {
+			movieScan, ratingScan := movieScans[scanType](), ratingScans[scanType]()
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: plan(movieScan, ratingScan)})
+			require.NoError(t, err)
+			require.Equal(t, expected, res)
+
+			explained, err := ExplainPlanNode(plan(movieScan, ratingScan))
+			require.NoError(t, err)
+			require.Contains(t, explained, "columns [title userId movieId]")
+		})
+	}
+}
+
+func TestScanRequiredColumns(t *testing.T) {
+	ratings := Table{headers: []string{"userId", "movieId", "rating"}, data: []map[string]interface{}{
+		{"userId": "1", "movieId": "10", "rating": "4.5"},
+		{"userId": "2", "movieId": "20", "rating": "bad"},
+		{"userId": "3", "movieId": "30", "rating": "3"},
+	}}
+	csvPath, ycfPath := writeRatingsCSV(t, ratings), writeRatingsYCFile(t, ratings)
+	jsonlPath := filepath.Join(t.TempDir(), "ratings.jsonl")
+	require.NoError(t, os.WriteFile(jsonlPath, []byte(`{"userId": "1", "movieId": "10", "rating": 4.5}
+{"userId": "2", "movieId": "20", "rating": "bad"}
+{"userId": "3", "movieId": "30", "rating": 3}
+`), 0666))
+	all := []Tuple{{data: map[string]interface{}{"movieId": "10"}}, {data: map[string]interface{}{"movieId": "20"}}, {data: map[string]interface{}{"movieId": "30"}}}
+	schema := map[string]ColumnType{"movieId": TYPESTRING, "rating": TYPEFLOAT}
+
+	tc := []struct {
+		name     string
+		node     PlanNode
+		expected []Tuple
+	}{
+		{name: "ycfile", node: &FileScanNode{path: ycfPath, sourceColumn: "file", columns: []string{"movieId", "title"}}, expected: all},
+		{name: "csv", node: &CSVScanNode{path: csvPath, columns: []string{"movieId"}}, expected: all},
+		{name: "csv malformed pruned cells are not parsed", node: &CSVScanNode{path: csvPath, schema: schema, columns: []string{"movieId"}}, expected: all},
+		{name: "csv malformed pruned cells still skip rows", node: &CSVScanNode{path: csvPath, schema: schema, malformed: MALFORMEDSKIP, columns: []string{"movieId"}}, expected: []Tuple{all[0], all[2]}},
+		{name: "json lines", node: &JSONLScanNode{path: jsonlPath, columns: []string{"movieId"}}, expected: all},
+		{name: "json lines malformed pruned values still skip rows", node: &JSONLScanNode{path: jsonlPath, schema: schema, malformed: MALFORMEDSKIP, columns: []string{"movieId"}}, expected: []Tuple{all[0], all[2]}},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.node})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+		})
+	}
+}
+
+func TestScanFilters(t *testing.T) {
+	_, ratings := syntheticMovieRatings(40, 300, 60)
+	ratings.headers = append(ratings.headers, "rating")
+	for i, r := range ratings.data {
+		r["rating"] = strconv.Itoa(i % 5)
+	}
+	ratings.data[20]["rating"] = "bad"
+	scans := scanNodes(t, ratings)
+	typedCSV := func() PlanNode {
+		csvn := scans["csv"]().(*CSVScanNode)
+		csvn.schema, csvn.malformed = map[string]ColumnType{"movieId": TYPEINT, "rating": TYPEINT}, MALFORMEDNULL
+		return csvn
+	}
+
+	plan := func(scan PlanNode, movieId string) PlanNode {
+		return &ProjectionNode{reqHeaders: []string{"userId"}, inputs: []PlanNode{
+			&FilterNode{header: "rating", operator: "=", cmpValue: "0", inputs: []PlanNode{
+				&FilterNode{header: "movieId", operator: "=", cmpValue: movieId, inputs: []PlanNode{scan}},
+			}},
+		}}
+	}
+	qe := QueryExecutor{}
+	expected, err := qe.ExecutePlan(&QueryDescriptor{planNode: plan(scans["table"](), "20")})
+	require.NoError(t, err)
+	require.Equal(t, []Tuple{{data: map[string]interface{}{"userId": "80"}}, {data: map[string]interface{}{"userId": "140"}}, {data: map[string]interface{}{"userId": "200"}}, {data: map[string]interface{}{"userId": "260"}}}, expected)
+
+	tc := []struct {
+		name     string
+		scan     func() PlanNode
+		movieId  string
+		expected []Tuple
+	}{
+		{name: "ycfile", scan: scans["ycfile"], movieId: "20", expected: expected},
+		{name: "ycfile compares numeric strings as numbers", scan: scans["ycfile"], movieId: "020", expected: expected},
+		{name: "ycfile compares other strings exactly", scan: scans["ycfile"], movieId: "20 ", expected: []Tuple{}},
+		{name: "csv", scan: scans["csv"], movieId: "20", expected: expected},
+		{name: "csv typed columns compare as numbers", scan: typedCSV, movieId: "020", expected: expected},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			scan := test.scan()
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: plan(scan, test.movieId)})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+
+			explained, err := ExplainPlanNode(plan(scan, test.movieId))
+			require.NoError(t, err)
+			require.Contains(t, explained, fmt.Sprintf("filters [rating = 0 movieId = %v] columns [userId rating movieId]", test.movieId))
+		})
+	}
+
+	t.Run("filtered rows are not converted", func(t *testing.T) {
+		scan := typedCSV().(*CSVScanNode)
+		scan.malformed = MALFORMEDERROR
+		_, err := qe.ExecutePlan(&QueryDescriptor{planNode: plan(scan, "21")})
+		require.NoError(t, err)
+
+		scan = typedCSV().(*CSVScanNode)
+		scan.malformed = MALFORMEDERROR
+		_, err = qe.ExecutePlan(&QueryDescriptor{planNode: plan(scan, "20")})
+		require.ErrorContains(t, err, `column rating: cannot parse "bad" as int`)
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*** Partitioned tables - Hive-style directory layouts like ratings/year=2019/month=03/part.ycf, where each directory level is a partition column
+Scans add the partition columns to every tuple, typed like inferred CSV columns from all of their values, and skip partitions ruled out by filters without opening any of their files ***/
+
+const HIVEDEFAULTPARTITION = "__HIVE_DEFAULT_PARTITION__" // directory value of a NULL partition
+
+type hivePartitions struct {
+	columns []string // partition columns, outermost directory level first - nil if the table isn't partitioned
+	types   []ColumnType
+	paths   []string                 // files of the partitions that weren't pruned
+	values  []map[string]interface{} // partition column values of the file at the same index of paths
+	total   int                      // number of partitions
+	skipped int                      // partitions pruned by filters
+}
+
+type partitionDir struct {
+	path   string
+	values []string // raw directory values, one per partition column
+}
+
+// resolves the files a scan reads: a directory of key=value directories is walked down to its partitions, the files of those that pass filters are read in lexical order
+// anything else is resolved by resolveSourcePaths
+func resolvePartitions(path string, filters []scanFilter) (*hivePartitions, error) {
+	dirs, columns, err := findPartitions(path)
+	if err != nil {
+		return nil, err
+	}
+	if columns == nil {
+		paths, err := resolveSourcePaths(path)
+		if err != nil {
+			return nil, err
+		}
+		return &hivePartitions{paths: paths, values: make([]map[string]interface{}, len(paths))}, nil
+	}
+
+	hp := &hivePartitions{columns: columns, total: len(dirs)}
+	for i := range columns {
+		values := []string{}
+		for _, dir := range dirs {
+			if dir.values[i] != HIVEDEFAULTPARTITION {
+				values = append(values, dir.values[i])
+			}
+		}
+		hp.types = append(hp.types, inferColumnType(values))
+	}
+
+	for _, dir := range dirs {
+		values := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if dir.values[i] != HIVEDEFAULTPARTITION {
+				values[column], _ = parseValue(dir.values[i], hp.types[i]) // the type was inferred from these values, so they all parse
+			} else {
+				values[column] = nil
+			}
+		}
+		if !partitionMatches(values, filters) {
+			hp.skipped++
+			continue
+		}
+
+		paths, err := listSourceFiles(dir.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			hp.paths = append(hp.paths, p)
+			hp.values = append(hp.values, values)
+		}
+	}
+	return hp, nil
+}
+
+// walks a directory of key=value directories down to its partitions, one level per partition column - columns is nil if path isn't partitioned
+// directories at the same level must name the same column, a directory without partitions below it at a level that has them is an empty partition
+func findPartitions(path string) ([]partitionDir, []string, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() { // files and glob patterns are left to resolveSourcePaths
+		return nil, nil, nil
+	}
+
+	dirs := []partitionDir{{path: path}}
+	var columns []string
+	for {
+		level, column := []partitionDir{}, ""
+		for _, dir := range dirs {
+			entries, err := os.ReadDir(dir.path)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, entry := range entries {
+				key, value, isPartition := strings.Cut(entry.Name(), "=")
+				if !entry.IsDir() || isHiddenSource(entry.Name()) || !isPartition || key == "" {
+					continue
+				}
+				if column == "" {
+					column = key
+				} else if key != column {
+					return nil, nil, fmt.Errorf("%v: partition column %v doesn't match partition column %v of the directories beside it", filepath.Join(dir.path, entry.Name()), key, column)
+				}
+				values := append(append([]string{}, dir.values...), value)
+				level = append(level, partitionDir{path: filepath.Join(dir.path, entry.Name()), values: values})
+			}
+		}
+
+		if column == "" {
+			return dirs, columns, nil
+		}
+		if searchStringInList(column, columns) != -1 {
+			return nil, nil, fmt.Errorf("%v: partition column %v appears at more than one level", path, column)
+		}
+		dirs, columns = level, append(columns, column)
+	}
+}
+
+// a partition passes unless a filter on one of its columns rejects its value, NULL partitions are rejected by every filter on their column
+func partitionMatches(values map[string]interface{}, filters []scanFilter) bool {
+	for _, sf := range filters {
+		value, isColumn := values[sf.header]
+		if !isColumn {
+			continue
+		}
+		if eq, _ := valuesEqual(value, sf.value); !eq {
+			return false
+		}
+	}
+	return true
+}
+
+// partition columns share tuples with the columns of the files, so they can't have the same names
+func (hp *hivePartitions) checkColumns(path string, columns []string) error {
+	for _, column := range hp.columns {
+		if searchStringInList(column, columns) != -1 {
+			return fmt.Errorf("%v: partition column %v clashes with a column of its files", path, column)
+		}
+	}
+	return nil
+}
+
+// adds the partition column values of a file to its tuples, only those in columns unless it is nil
+func (hp *hivePartitions) addValues(tuple Tuple, pathIdx int, columns []string) {
+	for column, value := range hp.values[pathIdx] {
+		if isRequiredColumn(column, columns) {
+			tuple.data[column] = value
+		}
+	}
+}
+
+// what a scan of path reads, resolving its partitions without opening any file
+func explainSource(path string, filters []scanFilter, columns []string) (string, error) {
+	hp, err := resolvePartitions(path, filters)
+	if err != nil {
+		return "", err
+	}
+	explained := fmt.Sprintf("%v (%d files)", path, len(hp.paths))
+	if hp.columns != nil {
+		explained += fmt.Sprintf(" partitioned by %v: %d of %d partitions skipped", hp.columns, hp.skipped, hp.total)
+	}
+	if len(filters) > 0 {
+		explained += fmt.Sprintf(" filters %v", filters)
+	}
+	if columns != nil {
+		explained += fmt.Sprintf(" columns %v", columns)
+	}
+	return explained, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writes ratings partitioned by year and month as CSV, JSON Lines and YCFile, a partition of each year holds a garbage file that fails any scan opening it
+func writePartitionedRatings(t *testing.T) string {
+	dir := t.TempDir()
+	partitions := []struct{ year, month string }{{"2019", "01"}, {"2019", "03"}, {"2020", "01"}, {"2020", "02"}, {"2020", HIVEDEFAULTPARTITION}}
+	for _, p := range partitions {
+		ratings := Table{headers: []string{"userId", "movieId"}}
+		jsonl := ""
+		for i := 0; i < 3; i++ {
+			r := map[string]interface{}{"userId": p.year + p.month, "movieId": strconv.Itoa(i)}
+			ratings.data = append(ratings.data, r)
+			jsonl += fmt.Sprintf(`{"userId": %q, "movieId": %q}`+"\n", r["userId"], r["movieId"])
+		}
+
+		for _, format := range []string{"csv", "jsonl", "ycf"} {
+			partitionDir := filepath.Join(dir, format, "year="+p.year, "month="+p.month)
+			require.NoError(t, os.MkdirAll(partitionDir, 0777))
+			require.NoError(t, os.WriteFile(filepath.Join(partitionDir, "_SUCCESS"), nil, 0666))
+			if p.month == "02" || p.month == HIVEDEFAULTPARTITION {
+				require.NoError(t, os.WriteFile(filepath.Join(partitionDir, "part."+format), []byte("garbage\x00{"), 0666))
+				continue
+			}
+
+			switch format {
+			case "csv":
+				require.NoError(t, os.Rename(writeRatingsCSV(t, ratings), filepath.Join(partitionDir, "part.csv")))
+			case "jsonl":
+				require.NoError(t, os.WriteFile(filepath.Join(partitionDir, "part.jsonl"), []byte(jsonl), 0666))
+			case "ycf":
+				require.NoError(t, os.Rename(writeRatingsYCFile(t, ratings), filepath.Join(partitionDir, "part.ycf")))
+			}
+		}
+	}
+	return dir
+}
+
+func TestPartitionPruning(t *testing.T) {
+	dir := writePartitionedRatings(t)
+	scans := map[string]func() PlanNode{
+		"csv":   func() PlanNode { return &CSVScanNode{path: filepath.Join(dir, "csv")} },
+		"jsonl": func() PlanNode { return &JSONLScanNode{path: filepath.Join(dir, "jsonl")} },
+		"ycf":   func() PlanNode { return &FileScanNode{path: filepath.Join(dir, "ycf")} },
+	}
+	ratings := func(year int64, month int64) []Tuple {
+		res := []Tuple{}
+		for i := 0; i < 3; i++ {
+			userId := fmt.Sprintf("%d%02d", year, month)
+			res = append(res, Tuple{data: map[string]interface{}{"userId": userId, "movieId": strconv.Itoa(i), "year": year, "month": month}})
+		}
+		return res
+	}
+
+	tc := []struct {
+		name     string
+		plan     func(scan PlanNode) PlanNode
+		expected []Tuple
+		explain  string
+	}{
+		{
+			name: "year and month",
+			plan: func(scan PlanNode) PlanNode {
+				return &FilterNode{header: "month", operator: "=", cmpValue: "3", inputs: []PlanNode{&FilterNode{header: "year", operator: "=", cmpValue: "2019", inputs: []PlanNode{scan}}}}
+			},
+			expected: ratings(2019, 3),
+			explain:  "partitioned by [year month]: 4 of 5 partitions skipped",
+		},
+		{
+			name: "month through a projection",
+			plan: func(scan PlanNode) PlanNode {
+				return &ProjectionNode{reqHeaders: []string{"userId", "movieId", "year", "month"}, inputs: []PlanNode{&FilterNode{header: "month", operator: "=", cmpValue: "01", inputs: []PlanNode{&ProjectionNode{reqHeaders: []string{"userId", "movieId", "year", "month"}, inputs: []PlanNode{scan}}}}}}
+			},
+			expected: append(ratings(2019, 1), ratings(2020, 1)...),
+			explain:  "3 of 5 partitions skipped",
+		},
+		{
+			name: "filter on a file column",
+			plan: func(scan PlanNode) PlanNode {
+				return &FilterNode{header: "movieId", operator: "=", cmpValue: "1", inputs: []PlanNode{&FilterNode{header: "year", operator: "=", cmpValue: "2019", inputs: []PlanNode{scan}}}}
+			},
+			expected: []Tuple{ratings(2019, 1)[1], ratings(2019, 3)[1]},
+			explain:  "3 of 5 partitions skipped",
+		},
+		{
+			name: "every partition pruned",
+			plan: func(scan PlanNode) PlanNode {
+				return &FilterNode{header: "year", operator: "=", cmpValue: "2021", inputs: []PlanNode{scan}}
+			},
+			expected: []Tuple{},
+			explain:  "(0 files) partitioned by [year month]: 5 of 5 partitions skipped",
+		},
+	}
+
+	for format, scan := range scans {
+		for _, test := range tc {
+			t.Run(format+" "+test.name, func(t *testing.T) {
+				qe := QueryExecutor{}
+				explained, err := qe.Explain(&QueryDescriptor{planNode: test.plan(scan())})
+				require.NoError(t, err)
+				require.Contains(t, explained, test.explain)
+
+				res, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.plan(scan())})
+				require.NoError(t, err)
+				require.ElementsMatch(t, test.expected, res)
+			})
+		}
+	}
+
+	// dates, the most common partition values, are typed as timestamps and still compare with date filters
+	dated := filepath.Join(t.TempDir(), "dated")
+	for _, dt := range []string{"2019-03-01", "2019-03-02"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dated, "dt="+dt), 0777))
+		require.NoError(t, os.WriteFile(filepath.Join(dated, "dt="+dt, "part.csv"), []byte("userId\n"+dt[8:]+"\n"), 0666))
+	}
+	datePlan := func() PlanNode {
+		return &FilterNode{header: "dt", operator: "=", cmpValue: "2019-03-01", inputs: []PlanNode{&CSVScanNode{path: dated}}}
+	}
+	qe := QueryExecutor{}
+	explained, err := qe.Explain(&QueryDescriptor{planNode: datePlan()})
+	require.NoError(t, err)
+	require.Contains(t, explained, "(1 files) partitioned by [dt]: 1 of 2 partitions skipped")
+	res, err := qe.ExecutePlan(&QueryDescriptor{planNode: datePlan()})
+	require.NoError(t, err)
+	require.Equal(t, []Tuple{{data: map[string]interface{}{"userId": "01", "dt": time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)}}}, res)
+}
+
+func TestPartitionColumns(t *testing.T) {
+	dir := writePartitionedRatings(t)
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "csv", "year=2020", "month=02")))
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "csv", "year=2020", "month="+HIVEDEFAULTPARTITION)))
+	node := &CSVScanNode{path: filepath.Join(dir, "csv")}
+
+	require.NoError(t, InitPlanNode(node))
+	defer ClosePlanNode(node)
+	for run := 0; run < 2; run++ {
+		res := []Tuple{}
+		for {
+			tuple, err := node.next()
+			require.NoError(t, err)
+			if tuple.data == nil {
+				break
+			}
+			res = append(res, tuple)
+		}
+		require.Len(t, res, 9)
+		require.Equal(t, map[string]interface{}{"userId": "201903", "movieId": "0", "year": int64(2019), "month": int64(3)}, res[3].data)
+		require.Equal(t, int64(2020), res[8].data["year"])
+		require.NoError(t, node.reset())
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "jsonl", "year=2019", "day=01"), 0777))
+	tc := []struct {
+		name string
+		node PlanNode
+		err  string
+	}{
+		{name: "columns differ across directories", node: &JSONLScanNode{path: filepath.Join(dir, "jsonl")}, err: "partition column month doesn't match partition column day"},
+		{name: "partition column clashes", node: &FileScanNode{path: filepath.Join(dir, "ycf"), sourceColumn: "year"}, err: "partition column year clashes"},
+	}
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			qe := QueryExecutor{}
+			_, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.node})
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
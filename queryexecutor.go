@@ -1,5 +1,7 @@
 package main
 
+import "context"
+
 var COMMANDS map[string]string = map[string]string{
 	"SELECT": "select",
 }
@@ -7,16 +9,64 @@ var COMMANDS map[string]string = map[string]string{
 type QueryDescriptor struct {
 	cmd      string
 	text     string
-	planNode PlanNode // top of the plan tree
+	planNode PlanNode      // top of the plan tree
+	qctx     *QueryContext // resources shared by the nodes of the plan, created by InitPlan if nil
+}
+
+// QueryContext holds the per-query resources plan nodes draw on
+type QueryContext struct {
+	spill  *SpillManager
+	memory *MemoryBudget
+}
+
+func NewQueryContext() *QueryContext {
+	qctx := &QueryContext{}
+	qctx.fillDefaults()
+	return qctx
+}
+
+// resources left unset are given their defaults
+func (qctx *QueryContext) fillDefaults() {
+	if qctx.spill == nil {
+		qctx.spill = NewSpillManager("", 0)
+	}
+	if qctx.memory == nil {
+		qctx.memory = NewMemoryBudget(0)
+	}
+}
+
+// nodes that need per-query resources are handed the query context before init
+type queryContextNode interface {
+	setQueryContext(qctx *QueryContext)
 }
 
 type QueryExecutor struct {
 }
 
 func (qe *QueryExecutor) ExecutePlan(qd *QueryDescriptor) ([]Tuple, error) {
-	res := []Tuple{}
-	qe.InitPlan(qd)
+	return qe.ExecutePlanContext(context.Background(), qd)
+}
+
+// executes the plan until it is exhausted or ctx is cancelled, the plan is closed and its spilled files removed however execution ends
+func (qe *QueryExecutor) ExecutePlanContext(ctx context.Context, qd *QueryDescriptor) (res []Tuple, err error) {
+	defer func() {
+		finishErr := qe.FinishPlan(qd)
+		if err == nil {
+			err = finishErr
+		}
+	}()
+
+	err = qe.InitPlan(qd)
+	if err != nil {
+		return nil, err
+	}
+
+	res = []Tuple{}
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		nextTuple, err := qd.planNode.next()
 		if err != nil {
 			return nil, err
@@ -26,15 +76,41 @@ func (qe *QueryExecutor) ExecutePlan(qd *QueryDescriptor) ([]Tuple, error) {
 		}
 		res = append(res, nextTuple)
 	}
-	qe.FinishPlan(qd)
 	return res, nil
 }
 
 func (qe *QueryExecutor) InitPlan(qd *QueryDescriptor) error {
+	if qd.qctx == nil {
+		qd.qctx = NewQueryContext()
+	}
+	qd.qctx.fillDefaults()
+
+	planNode, err := OptimizePlan(qd.planNode)
+	if err != nil {
+		return err
+	}
+	qd.planNode = planNode
+
 	curNode := qd.planNode
+	SetQueryContextPlanNode(curNode, qd.qctx)
 	return InitPlanNode(curNode)
 }
 
+func SetQueryContextPlanNode(pn PlanNode, qctx *QueryContext) {
+	if pn == nil {
+		return
+	}
+
+	if qcn, ok := pn.(queryContextNode); ok {
+		qcn.setQueryContext(qctx)
+	}
+
+	pnChildren, _ := pn.getInputs()
+	for _, pnChild := range pnChildren {
+		SetQueryContextPlanNode(pnChild, qctx)
+	}
+}
+
 func InitPlanNode(pn PlanNode) error {
 	if pn != nil {
 		err := pn.init()
@@ -81,7 +157,16 @@ func ClosePlanNode(pn PlanNode) error {
 	return nil
 }
 
+// closes the plan and removes whatever its nodes spilled to disk, even if closing fails
 func (qe *QueryExecutor) FinishPlan(qd *QueryDescriptor) error {
 	curNode := qd.planNode
-	return ClosePlanNode(curNode)
+	err := ClosePlanNode(curNode)
+
+	if qd.qctx != nil {
+		cleanupErr := qd.qctx.spill.cleanup()
+		if err == nil {
+			err = cleanupErr
+		}
+	}
+	return err
 }
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// movies 0 to nMovies-1 titled t0, t1... and ratings by users 0 to nRatings-1 of movies 0 to mod-1 in turn, all values strings
+func syntheticMovieRatings(nMovies int, nRatings int, mod int) (Table, Table) {
+	movies := Table{headers: []string{"movieId", "title"}}
+	for i := 0; i < nMovies; i++ {
+		movies.data = append(movies.data, map[string]interface{}{"movieId": strconv.Itoa(i), "title": fmt.Sprintf("t%d", i)})
+	}
+	ratings := Table{headers: []string{"userId", "movieId"}}
+	for i := 0; i < nRatings; i++ {
+		ratings.data = append(ratings.data, map[string]interface{}{"userId": strconv.Itoa(i), "movieId": strconv.Itoa(i % mod)})
+	}
+	return movies, ratings
+}
+
+// plan nodes scanning the same table from memory, a CSV file and a YCFile
+func scanNodes(t *testing.T, table Table) map[string]func() PlanNode {
+	csvPath, ycfPath := writeRatingsCSV(t, table), writeRatingsYCFile(t, table)
+	return map[string]func() PlanNode{
+		"table":  func() PlanNode { return &TableScanNode{table: table} },
+		"csv":    func() PlanNode { return &CSVScanNode{path: csvPath} },
+		"ycfile": func() PlanNode { return &FileScanNode{path: ycfPath} },
+	}
+}
+
+func TestRescan(t *testing.T) {
+	_, ratings := syntheticMovieRatings(40, 300, 60)
+
+	for name, scan := range scanNodes(t, ratings) {
+		t.Run(name, func(t *testing.T) {
+			tc := []struct {
+				name     string
+				planNode PlanNode
+				count    int
+			}{
+				{name: "scan", planNode: scan(), count: 300},
+				{name: "filter", planNode: &FilterNode{header: "movieId", operator: "=", cmpValue: "7", inputs: []PlanNode{scan()}}, count: 5},
+				{name: "limit", planNode: &LimitNode{limit: 10, inputs: []PlanNode{scan()}}, count: 10},
+			}
+			if fsn, ok := scan().(*FileScanNode); ok {
+				tc = append(tc, struct {
+					name     string
+					planNode PlanNode
+					count    int
+				}{name: "range", planNode: &FileScanNode{path: fsn.path, partition: 1, partitionCount: 3}, count: 100})
+			}
+
+			for _, test := range tc {
+				t.Run(test.name, func(t *testing.T) {
+					require.NoError(t, InitPlanNode(test.planNode))
+					defer ClosePlanNode(test.planNode)
+
+					var runs [][]Tuple
+					for run := 0; run < 3; run++ {
+						res := []Tuple{}
+						for {
+							tuple, err := test.planNode.next()
+							require.NoError(t, err)
+							if tuple.data == nil {
+								break
+							}
+							res = append(res, tuple)
+						}
+						require.Len(t, res, test.count)
+						runs = append(runs, res)
+						require.NoError(t, test.planNode.reset())
+					}
+					require.Equal(t, runs[0], runs[1])
+					require.Equal(t, runs[0], runs[2])
+				})
+			}
+		})
+	}
+}
+
+func TestJoinsAcrossScanTypes(t *testing.T) {
+	movies, ratings := syntheticMovieRatings(40, 300, 60)
+	movieScans, ratingScans := scanNodes(t, movies), scanNodes(t, ratings)
+	headers := []string{"movieId", "movieId"}
+
+	joins := map[string]func() PlanNode{
+		"naive nested loop": func() PlanNode { return &NaiveNestedJoinNode{headers: headers} },
+		"chunk nested loop": func() PlanNode { return &ChunkNestedJoinNode{headers: headers, numberOfPages: 1} },
+		"hash join": func() PlanNode {
+			return &HashJoinNode{reqHeaders: headers, partitionCount: 4, headersInOrder: [][]string{movies.headers, ratings.headers}}
+		},
+		"hash join as inner of a nested loop": func() PlanNode { // the hash join is reset once per chunk of movies
+			return &ChunkNestedJoinNode{predicate: &CmpExpr{operator: "=", left: &ColumnExpr{header: "movieId"}, right: &ColumnExpr{header: "ratedMovieId"}}, numberOfPages: 1, inputs: []PlanNode{nil, &ProjectionNode{
+				reqHeaders: []string{"ratedMovieId", "userId"},
+				inputs:     []PlanNode{&renameNode{from: "movieId", to: "ratedMovieId", inputs: []PlanNode{&HashJoinNode{reqHeaders: headers, partitionCount: 2, bloomFilter: true, headersInOrder: [][]string{movies.headers, ratings.headers}, inputs: []PlanNode{&TableScanNode{table: movies}, nil}}}}},
+			}}}
+		},
+	}
+
+	var expected []Tuple
+	for _, r := range ratings.data {
+		for _, m := range movies.data {
+			if r["movieId"] == m["movieId"] {
+				expected = append(expected, Tuple{data: map[string]interface{}{"movieId": m["movieId"], "title": m["title"], "userId": r["userId"]}})
+			}
+		}
+	}
+	require.Len(t, expected, 200)
+
+	for joinName, join := range joins {
+		for outerName, outer := range movieScans {
+			for innerName, inner := range ratingScans {
+				t.Run(fmt.Sprintf("%s over %s and %s", joinName, outerName, innerName), func(t *testing.T) {
+					joinNode := join()
+					inputs, _ := joinNode.getInputs()
+					if inputs == nil {
+						joinNode.setInputs([]PlanNode{outer(), inner()})
+					} else { // join of movies with a join of movies and ratings
+						inputs[0] = outer()
+						hashJoin := inputs[1].(*ProjectionNode).inputs[0].(*renameNode).inputs[0].(*HashJoinNode)
+						hashJoin.inputs[1] = inner()
+					}
+
+					qe := QueryExecutor{}
+					res, err := qe.ExecutePlan(&QueryDescriptor{planNode: &ProjectionNode{reqHeaders: []string{"movieId", "title", "userId"}, inputs: []PlanNode{joinNode}}, qctx: &QueryContext{spill: NewSpillManager(t.TempDir(), 0)}})
+					require.NoError(t, err)
+					require.ElementsMatch(t, expected, res)
+				})
+			}
+		}
+	}
+}
+
+// renames one header of its input's tuples
+type renameNode struct {
+	from, to string
+	inputs   []PlanNode
+}
+
+func (rn *renameNode) init() error {
+	return nil
+}
+
+func (rn *renameNode) next() (Tuple, error) {
+	tuple, err := rn.inputs[0].next()
+	if err != nil || tuple.data == nil {
+		return tuple, err
+	}
+	data := map[string]interface{}{}
+	for k, v := range tuple.data {
+		if k == rn.from {
+			k = rn.to
+		}
+		data[k] = v
+	}
+	return Tuple{data: data}, nil
+}
+
+func (rn *renameNode) close() error {
+	return nil
+}
+
+func (rn *renameNode) getInputs() ([]PlanNode, error) {
+	return rn.inputs, nil
+}
+
+func (rn *renameNode) reset() error {
+	return resetPlanNode(rn)
+}
+
+func (rn *renameNode) setInputs(inps []PlanNode) {
+	rn.inputs = inps
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+/*** Column types - typed values of text sources, with NULL represented by nil
+Types are inferred from a sample of values as the narrowest one they all parse as, empty cells are NULL and don't narrow anything ***/
+
+type ColumnType int
+
+const (
+	TYPESTRING    ColumnType = iota
+	TYPEINT                  // int64
+	TYPEFLOAT                // float64
+	TYPEBOOL                 // bool
+	TYPETIMESTAMP            // time.Time
+)
+
+// what a scan does with a cell that doesn't parse as its column's type
+const (
+	MALFORMEDERROR = iota // fail the scan
+	MALFORMEDNULL         // replace the value with NULL
+	MALFORMEDSKIP         // drop the whole row
+)
+
+const CSVSAMPLEROWS = 100 // default number of rows types are inferred from
+
+var TIMESTAMPLAYOUTS = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// candidate types in the order they are tried, narrowest first - a string column accepts anything
+var inferenceOrder = []ColumnType{TYPEINT, TYPEFLOAT, TYPEBOOL, TYPETIMESTAMP}
+
+func (ct ColumnType) String() string {
+	switch ct {
+	case TYPEINT:
+		return "int"
+	case TYPEFLOAT:
+		return "float"
+	case TYPEBOOL:
+		return "bool"
+	case TYPETIMESTAMP:
+		return "timestamp"
+	}
+	return "string"
+}
+
+// converts a text value to its column's type, empty values of non-string columns are NULL
+func parseValue(s string, ct ColumnType) (interface{}, error) {
+	if ct == TYPESTRING {
+		return s, nil
+	}
+	if s == "" {
+		return nil, nil
+	}
+
+	switch ct {
+	case TYPEINT:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v, nil
+		}
+	case TYPEFLOAT:
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v, nil
+		}
+	case TYPEBOOL:
+		if v, err := strconv.ParseBool(s); err == nil {
+			return v, nil
+		}
+	case TYPETIMESTAMP:
+		for _, layout := range TIMESTAMPLAYOUTS {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot parse %q as %v", s, ct)
+}
+
+// narrowest type every non-empty value of a column parses as
+func inferColumnType(values []string) ColumnType {
+	candidates := inferenceOrder
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		remaining := candidates[:0:0]
+		for _, ct := range candidates {
+			if _, err := parseValue(v, ct); err == nil {
+				remaining = append(remaining, ct)
+			}
+		}
+		candidates = remaining
+		if len(candidates) == 0 {
+			return TYPESTRING
+		}
+	}
+
+	if len(candidates) == len(inferenceOrder) { // nothing but NULLs
+		return TYPESTRING
+	}
+	return candidates[0]
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferColumnType(t *testing.T) {
+	tc := []struct {
+		name   string
+		values []string
+		typ    ColumnType
+	}{
+		{name: "int", values: []string{"1", "-20", "", "300"}, typ: TYPEINT},
+		{name: "float", values: []string{"1", "4.5", "3e2"}, typ: TYPEFLOAT},
+		{name: "bool", values: []string{"true", "false", "TRUE"}, typ: TYPEBOOL},
+		{name: "timestamp", values: []string{"2019-03-01", "2019-03-01 10:00:00", "2019-03-01T10:00:00Z"}, typ: TYPETIMESTAMP},
+		{name: "string", values: []string{"1", "Toy Story (1995)"}, typ: TYPESTRING},
+		{name: "only NULLs", values: []string{"", ""}, typ: TYPESTRING},
+		{name: "no values", values: nil, typ: TYPESTRING},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.typ, inferColumnType(test.values))
+		})
+	}
+}
+
+func TestCSVScanTypes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ratings.csv")
+	content := "userId,movieId,rating,liked,ratedAt,comment\n" +
+		"1,11,4.5,true,2019-03-01 10:00:00,good\n" +
+		"2,,3,false,2019-03-02 11:30:00,\n" +
+		"3,12,oops,true,2019-03-03 12:00:00,bad rating\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0666))
+
+	row1 := map[string]interface{}{"userId": int64(1), "movieId": int64(11), "rating": 4.5, "liked": true, "ratedAt": time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC), "comment": "good"}
+	row2 := map[string]interface{}{"userId": int64(2), "movieId": nil, "rating": 3.0, "liked": false, "ratedAt": time.Date(2019, 3, 2, 11, 30, 0, 0, time.UTC), "comment": ""}
+	row3 := map[string]interface{}{"userId": int64(3), "movieId": int64(12), "rating": nil, "liked": true, "ratedAt": time.Date(2019, 3, 3, 12, 0, 0, 0, time.UTC), "comment": "bad rating"}
+
+	tc := []struct {
+		name     string
+		node     *CSVScanNode
+		expected []Tuple
+		err      string
+	}{
+		{
+			name: "strings without inference",
+			node: &CSVScanNode{path: path, sampleSize: 2},
+			expected: []Tuple{
+				{data: map[string]interface{}{"userId": "1", "movieId": "11", "rating": "4.5", "liked": "true", "ratedAt": "2019-03-01 10:00:00", "comment": "good"}},
+				{data: map[string]interface{}{"userId": "2", "movieId": "", "rating": "3", "liked": "false", "ratedAt": "2019-03-02 11:30:00", "comment": ""}},
+				{data: map[string]interface{}{"userId": "3", "movieId": "12", "rating": "oops", "liked": "true", "ratedAt": "2019-03-03 12:00:00", "comment": "bad rating"}},
+			},
+		},
+		{
+			name: "malformed cell errors",
+			node: &CSVScanNode{path: path, inferTypes: true, sampleSize: 2},
+			err:  "ratings.csv: line 4: column rating: cannot parse \"oops\" as float",
+		},
+		{
+			name:     "malformed cell is NULL",
+			node:     &CSVScanNode{path: path, inferTypes: true, sampleSize: 2, malformed: MALFORMEDNULL},
+			expected: []Tuple{{data: row1}, {data: row2}, {data: row3}},
+		},
+		{
+			name:     "malformed row is skipped",
+			node:     &CSVScanNode{path: path, inferTypes: true, sampleSize: 2, malformed: MALFORMEDSKIP},
+			expected: []Tuple{{data: row1}, {data: row2}},
+		},
+		{
+			name: "schema overrides inference",
+			node: &CSVScanNode{path: path, inferTypes: true, schema: map[string]ColumnType{"rating": TYPESTRING, "userId": TYPEFLOAT}},
+			expected: []Tuple{
+				{data: map[string]interface{}{"userId": 1.0, "movieId": int64(11), "rating": "4.5", "liked": true, "ratedAt": row1["ratedAt"], "comment": "good"}},
+				{data: map[string]interface{}{"userId": 2.0, "movieId": nil, "rating": "3", "liked": false, "ratedAt": row2["ratedAt"], "comment": ""}},
+				{data: map[string]interface{}{"userId": 3.0, "movieId": int64(12), "rating": "oops", "liked": true, "ratedAt": row3["ratedAt"], "comment": "bad rating"}},
+			},
+		},
+		{
+			name: "schema column not in file",
+			node: &CSVScanNode{path: path, schema: map[string]ColumnType{"timestamp": TYPEINT}},
+			err:  "schema column timestamp not in headers",
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.node})
+			if test.err != "" {
+				require.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+		})
+	}
+}
+
+func TestTypedFilters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratings.csv")
+	require.NoError(t, os.WriteFile(path, []byte("userId,day,liked\n1,2019-03-01,True\n2,2019-03-02,false\n3,2019-03-01T00:00:00Z,TRUE\n4,,\n"), 0666))
+
+	tc := []struct {
+		name    string
+		header  string
+		value   string
+		typed   []string // userIds of the matching rows with inferred types
+		untyped []string // and without
+	}{
+		{name: "timestamp equals a date", header: "day", value: "2019-03-01", typed: []string{"1", "3"}, untyped: []string{"1"}},
+		{name: "timestamp equals a timestamp", header: "day", value: "2019-03-02 00:00:00", typed: []string{"2"}, untyped: []string{}},
+		{name: "timestamp and an unparsable value", header: "day", value: "yesterday", typed: []string{}, untyped: []string{}},
+		{name: "bool equals its text", header: "liked", value: "True", typed: []string{"1", "3"}, untyped: []string{"1"}},
+		{name: "bool equals another spelling", header: "liked", value: "f", typed: []string{"2"}, untyped: []string{}},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			for inferTypes, expected := range map[bool][]string{true: test.typed, false: test.untyped} {
+				qe := QueryExecutor{}
+				res, err := qe.ExecutePlan(&QueryDescriptor{planNode: &FilterNode{header: test.header, operator: "=", cmpValue: test.value, inputs: []PlanNode{&CSVScanNode{path: path, inferTypes: inferTypes}}}})
+				require.NoError(t, err)
+				userIds := []string{}
+				for _, tuple := range res {
+					userIds = append(userIds, fmt.Sprint(tuple.data["userId"]))
+				}
+				require.Equal(t, expected, userIds, "inferred types: %v", inferTypes)
+			}
+		})
+	}
+}
+
+func TestTypedJoinsAndAggregates(t *testing.T) {
+	dir := t.TempDir()
+	moviesPath, ratingsPath := filepath.Join(dir, "movies.csv"), filepath.Join(dir, "ratings.csv")
+	require.NoError(t, os.WriteFile(moviesPath, []byte("movieId,title\n1,Toy Story (1995)\n2,Jumanji (1995)\n,Unknown\n"), 0666))
+	require.NoError(t, os.WriteFile(ratingsPath, []byte("userId,movieId,rating\n1,1,4\n1,2,3.5\n2,1,5\n2,,1\n3,2,2\n"), 0666))
+	movieHeaders, ratingHeaders := []string{"movieId", "title"}, []string{"userId", "movieId", "rating"}
+
+	expected := []Tuple{
+		{data: map[string]interface{}{"movieId": int64(1), "title": "Toy Story (1995)", "userId": int64(1), "rating": 4.0}},
+		{data: map[string]interface{}{"movieId": int64(2), "title": "Jumanji (1995)", "userId": int64(1), "rating": 3.5}},
+		{data: map[string]interface{}{"movieId": int64(1), "title": "Toy Story (1995)", "userId": int64(2), "rating": 5.0}},
+		{data: map[string]interface{}{"movieId": int64(2), "title": "Jumanji (1995)", "userId": int64(3), "rating": 2.0}},
+	}
+
+	tc := []struct {
+		name     string
+		joinNode PlanNode
+	}{
+		{name: "naive nested loop", joinNode: &NaiveNestedJoinNode{headers: []string{"movieId", "movieId"}}},
+		{name: "chunk nested loop", joinNode: &ChunkNestedJoinNode{headers: []string{"movieId", "movieId"}, numberOfPages: 1}},
+		{name: "hash join", joinNode: &HashJoinNode{reqHeaders: []string{"movieId", "movieId"}, partitionCount: 2, headersInOrder: [][]string{movieHeaders, ratingHeaders}}},
+		{name: "hash join with bloom filter", joinNode: &HashJoinNode{reqHeaders: []string{"movieId", "movieId"}, partitionCount: 2, hybrid: true, bloomFilter: true, headersInOrder: [][]string{movieHeaders, ratingHeaders}}},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.joinNode.setInputs([]PlanNode{&CSVScanNode{path: moviesPath, inferTypes: true}, &CSVScanNode{path: ratingsPath, inferTypes: true}})
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlan(&QueryDescriptor{planNode: test.joinNode, qctx: &QueryContext{spill: NewSpillManager(t.TempDir(), 0)}})
+			require.NoError(t, err)
+			require.ElementsMatch(t, expected, res, "NULL keys must not match")
+		})
+	}
+
+	t.Run("filter and average", func(t *testing.T) {
+		qe := QueryExecutor{}
+		res, err := qe.ExecutePlan(&QueryDescriptor{planNode: &AvgNode{header: "rating", inputs: []PlanNode{
+			&FilterNode{header: "userId", operator: "=", cmpValue: "1", inputs: []PlanNode{&CSVScanNode{path: ratingsPath, inferTypes: true}}},
+		}}})
+		require.NoError(t, err)
+		require.Equal(t, []Tuple{{data: map[string]interface{}{"average": 3.75}}}, res)
+
+		res, err = qe.ExecutePlan(&QueryDescriptor{planNode: &AvgNode{header: "movieId", inputs: []PlanNode{&CSVScanNode{path: ratingsPath, inferTypes: true}}}})
+		require.NoError(t, err)
+		require.Equal(t, []Tuple{{data: map[string]interface{}{"average": 1.5}}}, res, "NULLs are left out of averages")
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chettriyuvraj/query-executor/ycfile"
+)
+
+/*** Source files - text sources are opened through openSource, which decompresses gzipped files on the fly
+A file is gzipped if it starts with the gzip magic bytes or is named *.gz, reopening it restarts the decompression stream ***/
+
+type sourceFile struct {
+	io.Reader // the file itself or its decompressed contents
+	file      *os.File
+	gz        *gzip.Reader // nil unless the file is gzipped
+}
+
+func openSource(path string) (*sourceFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(file)
+	magic, _ := br.Peek(len(ycfile.GZIPMAGIC))
+	if !bytes.Equal(magic, ycfile.GZIPMAGIC) && filepath.Ext(path) != ".gz" {
+		return &sourceFile{Reader: br, file: file}, nil
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &sourceFile{Reader: gz, file: file, gz: gz}, nil
+}
+
+func (sf *sourceFile) Close() error {
+	if sf.gz != nil {
+		sf.gz.Close()
+	}
+	return sf.file.Close()
+}
+
+// expands the path of a scan into the files it reads as one table, in lexical order: a directory is every file directly in it, a glob pattern every file it matches
+// hidden files and files starting with "_" (e.g. _SUCCESS markers) in a directory are left out
+func resolveSourcePaths(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err == nil && !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var paths []string
+	if err == nil {
+		paths, err = listSourceFiles(path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		matches, globErr := filepath.Glob(path)
+		if globErr != nil {
+			return nil, globErr
+		}
+		if matches == nil { // neither a file, a directory nor a pattern matching anything
+			return nil, err
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				paths = append(paths, match)
+			}
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files to scan in %v", path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// files directly in a directory, in lexical order
+func listSourceFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || isHiddenSource(entry.Name()) {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	return paths, nil
+}
+
+func isHiddenSource(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+/*** Spill Manager - hands out temp directories to spilling operators (joins, sorts, aggregations) of a query, enforces a disk quota across them and removes everything once the query finishes ***/
+
+const SPILLDISKQUOTA = 1 << 30 // default number of bytes a query may spill to disk at once
+
+var errSpillQuotaExceeded = fmt.Errorf("spill disk quota exceeded")
+
+type SpillManager struct {
+	mu       sync.Mutex
+	baseDir  string           // directory the query directory is created in, os.TempDir() if empty
+	quota    int64            // bytes that may be on disk at once
+	queryDir string           // created with the first operator directory, removed with the last
+	dirs     map[string]int64 // operator directory -> bytes spilled into it
+	used     int64
+}
+
+func NewSpillManager(baseDir string, quota int64) *SpillManager {
+	if quota <= 0 {
+		quota = SPILLDISKQUOTA
+	}
+	return &SpillManager{baseDir: baseDir, quota: quota, dirs: map[string]int64{}}
+}
+
+// creates a unique directory for one operator of the query, e.g. <tmp>/query-123/hashjoin-456
+func (sm *SpillManager) operatorDir(operator string) (string, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.queryDir == "" {
+		queryDir, err := os.MkdirTemp(sm.baseDir, "query-")
+		if err != nil {
+			return "", err
+		}
+		sm.queryDir = queryDir
+	}
+
+	dir, err := os.MkdirTemp(sm.queryDir, fmt.Sprintf("%s-", operator))
+	if err != nil {
+		return "", err
+	}
+	sm.dirs[dir] = 0
+	return dir, nil
+}
+
+// accounts for n bytes about to be written into dir, failing if the query's quota would be exceeded
+func (sm *SpillManager) reserve(dir string, n int64) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.used+n > sm.quota {
+		return fmt.Errorf("%w: %d of %d bytes in use, %d requested", errSpillQuotaExceeded, sm.used, sm.quota, n)
+	}
+	sm.used += n
+	sm.dirs[dir] += n
+	return nil
+}
+
+// appends data to a file in an operator directory, counting it towards the quota
+func (sm *SpillManager) appendFile(dir string, path string, data []byte) error {
+	err := sm.reserve(dir, int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
+
+// removes a single spilled file, returning its bytes to the quota
+func (sm *SpillManager) removeFile(dir string, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.used -= info.Size()
+	sm.dirs[dir] -= info.Size()
+	return nil
+}
+
+// removes an operator directory once the operator is done with it, the query directory goes with the last one
+func (sm *SpillManager) releaseDir(dir string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	size, exists := sm.dirs[dir]
+	if !exists {
+		return nil
+	}
+
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	sm.used -= size
+	delete(sm.dirs, dir)
+
+	if len(sm.dirs) == 0 && sm.queryDir != "" {
+		err := os.RemoveAll(sm.queryDir)
+		sm.queryDir = ""
+		return err
+	}
+	return nil
+}
+
+// removes everything spilled by the query, operators that didn't get to release their directories (errors, cancellation, panics) included
+func (sm *SpillManager) cleanup() error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.queryDir == "" {
+		return nil
+	}
+
+	err := os.RemoveAll(sm.queryDir)
+	sm.queryDir, sm.dirs, sm.used = "", map[string]int64{}, 0
+	return err
+}
+
+func (sm *SpillManager) inUse() int64 {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.used
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillManager(t *testing.T) {
+	movies, ratings := syntheticMovieRatings(200, 1000, 200)
+	for i, r := range ratings.data { // 50 users rate 20 movies each
+		r["userId"] = strconv.Itoa(i % 50)
+	}
+	users := Table{headers: []string{"uid", "name"}}
+	for i := 0; i < 50; i++ {
+		users.data = append(users.data, map[string]interface{}{"uid": strconv.Itoa(i), "name": "u" + strconv.Itoa(i)})
+	}
+
+	/* users join (movies join ratings) - both joins spill at the same time */
+	nestedJoins := func() PlanNode {
+		inner := &HashJoinNode{reqHeaders: []string{"movieId", "movieId"}, partitionCount: 4, headersInOrder: [][]string{movies.headers, ratings.headers}, inputs: []PlanNode{&TableScanNode{table: movies}, &TableScanNode{table: ratings}}}
+		return &HashJoinNode{reqHeaders: []string{"uid", "userId"}, partitionCount: 4, headersInOrder: [][]string{users.headers, {"userId", "movieId", "title"}}, inputs: []PlanNode{&TableScanNode{table: users}, inner}}
+	}
+
+	tc := []struct {
+		name        string
+		quota       int64
+		ctx         func() context.Context
+		expectedErr error
+	}{
+		{name: "concurrent joins", ctx: context.Background},
+		{name: "quota exceeded", quota: 1024, ctx: context.Background, expectedErr: errSpillQuotaExceeded},
+		{name: "cancelled", ctx: func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx
+		}, expectedErr: context.Canceled},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			qd := QueryDescriptor{cmd: COMMANDS["SELECT"], planNode: nestedJoins(), qctx: &QueryContext{spill: NewSpillManager(baseDir, test.quota)}}
+			qe := QueryExecutor{}
+			res, err := qe.ExecutePlanContext(test.ctx(), &qd)
+
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, res, 1000)
+			}
+
+			entries, err := os.ReadDir(baseDir)
+			require.NoError(t, err)
+			require.Empty(t, entries, "spilled files must be removed however the query ends")
+			require.Zero(t, qd.qctx.spill.inUse())
+		})
+	}
+}
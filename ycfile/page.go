@@ -0,0 +1,232 @@
+package ycfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+/*** Pages - records of a file are stored in PAGESIZE pages following the header, which is itself padded to a whole number of pages
+Every page starts with a header, records follow it back to back and never cross into the next page, the rest of the page is free space
+The one exception is a record with variable length fields too large for a page: it starts a page of its own and runs on into overflow pages after it ***/
+
+const PAGEHEADERSIZE = 8 // 2 bytes record count, 2 bytes free space, 1 byte flags, 3 bytes reserved
+
+const PAGEOVERFLOW = 0x01 // page flag of an overflow page, which holds nothing but the continuation of the record on the page before it
+
+type PageHeader struct {
+	RecordCount int  // records starting on the page, 0 for overflow pages
+	FreeSpace   int  // bytes left after the last record of the page
+	Flags       byte // PAGEOVERFLOW, the other bits are room to mark pages carrying stats or checksums
+}
+
+func ParsePageHeader(page []byte) (PageHeader, error) {
+	if len(page) < PAGEHEADERSIZE {
+		return PageHeader{}, fmt.Errorf("page of %d bytes too short for a page header", len(page))
+	}
+	ph := PageHeader{RecordCount: int(binary.BigEndian.Uint16(page[0:2])), FreeSpace: int(binary.BigEndian.Uint16(page[2:4])), Flags: page[4]}
+	if ph.FreeSpace > PAGESIZE-PAGEHEADERSIZE {
+		return PageHeader{}, fmt.Errorf("corrupt page header, %d bytes free in a page of %d", ph.FreeSpace, PAGESIZE)
+	}
+	return ph, nil
+}
+
+func (ph PageHeader) encode() []byte {
+	b := make([]byte, PAGEHEADERSIZE)
+	binary.BigEndian.PutUint16(b[0:2], uint16(ph.RecordCount))
+	binary.BigEndian.PutUint16(b[2:4], uint16(ph.FreeSpace))
+	b[4] = ph.Flags
+	return b
+}
+
+// records and the bytes after them, up to the free space
+func pageRecordBytes(page []byte, ph PageHeader) []byte {
+	return page[PAGEHEADERSIZE : PAGESIZE-ph.FreeSpace]
+}
+
+// finds the last page of a paged file, where the next record is written if it fits
+func (w *YCFileWriter) findLastPage() error {
+	info, err := w.ycf.file.Stat()
+	if err != nil {
+		return err
+	}
+	pagesSize := info.Size() - int64(w.ycf.dataOffset())
+	if pagesSize < 0 || pagesSize%PAGESIZE != 0 {
+		return fmt.Errorf("%v: %d bytes after the header aren't a whole number of pages", w.ycf.file.Name(), pagesSize)
+	}
+	if pagesSize == 0 {
+		return nil
+	}
+
+	w.page = info.Size() - PAGESIZE
+	header := make([]byte, PAGEHEADERSIZE)
+	if _, err := w.ycf.file.ReadAt(header, w.page); err != nil {
+		return err
+	}
+	w.pageHeader, err = ParsePageHeader(header)
+	return err
+}
+
+// appends an encoded record to the last page, or to a new page if it doesn't fit there - a record too large for any page is spread over a new page and overflow pages
+func (w *YCFileWriter) writeToPage(record []byte) error {
+	if _, fixed := w.ycf.recordSize(); fixed && len(record) > PAGESIZE-PAGEHEADERSIZE { // Seek finds fixed width records by arithmetic, so they can't overflow
+		return fmt.Errorf("record of %d bytes doesn't fit in a page, which holds %d", len(record), PAGESIZE-PAGEHEADERSIZE)
+	}
+
+	if w.page == 0 || w.pageHeader.Flags&PAGEOVERFLOW != 0 || len(record) > w.pageHeader.FreeSpace {
+		if err := w.newPage(0); err != nil {
+			return err
+		}
+	}
+	w.pageHeader.RecordCount++
+	for {
+		n := len(record)
+		if n > w.pageHeader.FreeSpace {
+			n = w.pageHeader.FreeSpace
+		}
+		if err := w.writePageBytes(record[:n]); err != nil {
+			return err
+		}
+		record = record[n:]
+		if len(record) == 0 {
+			return nil
+		}
+		if err := w.newPage(PAGEOVERFLOW); err != nil {
+			return err
+		}
+	}
+}
+
+// starts an empty page after the last one
+func (w *YCFileWriter) newPage(flags byte) error {
+	page := int64(w.ycf.dataOffset())
+	if w.page != 0 {
+		page = w.page + PAGESIZE
+	}
+	ph := PageHeader{FreeSpace: PAGESIZE - PAGEHEADERSIZE, Flags: flags}
+	if _, err := w.ycf.file.WriteAt(append(ph.encode(), make([]byte, PAGESIZE-PAGEHEADERSIZE)...), page); err != nil { // pages are written whole, so the file is always a whole number of them
+		return err
+	}
+	w.page, w.pageHeader = page, ph
+	return nil
+}
+
+// writes b to the free space of the last page and updates its header
+func (w *YCFileWriter) writePageBytes(b []byte) error {
+	ph := w.pageHeader
+	if _, err := w.ycf.file.WriteAt(b, w.page+int64(PAGESIZE-ph.FreeSpace)); err != nil {
+		return err
+	}
+	ph.FreeSpace -= len(b)
+	if _, err := w.ycf.file.WriteAt(ph.encode(), w.page); err != nil {
+		return err
+	}
+	w.pageHeader = ph
+	return nil
+}
+
+// source of the next record of a paged file, moving on to the next page with records once those of the current one have been read
+func (r *YCFileReader) nextPageRecord() (io.Reader, error) {
+	for r.pageRecords == 0 {
+		if err := r.loadPage(); err != nil {
+			return nil, err
+		}
+	}
+	r.pageRecords--
+	return pageSource{r}, nil
+}
+
+// reads the records of the current page, running on into the overflow pages after it when a record doesn't fit in the page
+type pageSource struct {
+	r *YCFileReader
+}
+
+func (ps pageSource) Read(b []byte) (int, error) {
+	n, err := ps.r.page.Read(b)
+	if err != io.EOF {
+		return n, err
+	}
+
+	if err := ps.r.loadPage(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if ps.r.pageFlags&PAGEOVERFLOW == 0 {
+		return 0, fmt.Errorf("corrupt page, a record runs past its end into a page that doesn't continue it")
+	}
+	return ps.r.page.Read(b)
+}
+
+// reads the next page of src into pageBuf, io.EOF if there are no more
+func (r *YCFileReader) loadPage() error {
+	if r.pageBuf == nil {
+		r.pageBuf = make([]byte, PAGESIZE)
+	}
+	if err := readRecordBytes(r.src, r.pageBuf, false); err != nil {
+		return err
+	}
+	ph, err := ParsePageHeader(r.pageBuf)
+	if err != nil {
+		return err
+	}
+	r.page.Reset(pageRecordBytes(r.pageBuf, ph))
+	r.pageRecords, r.pageFlags = ph.RecordCount, ph.Flags
+	return nil
+}
+
+// reads the next whole page, header included, for callers managing pages themselves like a buffer pool - Read continues with the page after it
+func (r *YCFileReader) ReadPage() ([]byte, error) {
+	if !r.ycf.paged {
+		return nil, fmt.Errorf("file has no pages, its records are stored contiguously after the header")
+	}
+
+	page := make([]byte, PAGESIZE)
+	if err := readRecordBytes(r.src, page, false); err != nil {
+		return nil, err
+	}
+	ph, err := ParsePageHeader(page)
+	if err != nil {
+		return nil, err
+	}
+	r.next += r.pageRecords + ph.RecordCount // records of the current page Read hasn't reached are skipped too
+	r.pageRecords = 0
+	return page, nil
+}
+
+// decodes the records of a page returned by ReadPage, applying the reader's selected fields and field filters - records failing a filter are left out
+// a record running on into overflow pages can only be read with Read
+func (r *YCFileReader) PageRecords(page []byte) ([]YCFileRecord, error) {
+	if len(page) != PAGESIZE {
+		return nil, fmt.Errorf("page of %d bytes, pages are %d", len(page), PAGESIZE)
+	}
+	ph, err := ParsePageHeader(page)
+	if err != nil {
+		return nil, err
+	}
+
+	src := bytes.NewReader(pageRecordBytes(page, ph))
+	records := []YCFileRecord{}
+	for i := 0; i < ph.RecordCount; i++ {
+		if err := r.readRecord(src); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = io.ErrUnexpectedEOF
+				if i == ph.RecordCount-1 && ph.FreeSpace == 0 {
+					err = fmt.Errorf("record %d of the page runs on into overflow pages", i)
+				}
+			}
+			return nil, err
+		}
+		if !r.matchFilters() {
+			continue
+		}
+		record, err := r.decodeRecord()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,177 @@
+package ycfile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+/*** Random access - records are numbered from 0 in the order they were written
+Without variable length fields every record has the same size, so its position is arithmetic: its page is the record number over the records a page holds, legacy files have no pages at all
+Otherwise the page of a record is found from the page headers, and the records of the page before it are skipped - legacy files are skipped from the start
+Gzipped files can't seek, they are decompressed again from the start and skipped up to the record ***/
+
+// the next Read returns record i, seeking to RecordCount has the next Read return io.EOF
+func (r *YCFileReader) Seek(i int) error {
+	count := r.RecordCount()
+	if i < 0 || i > count {
+		return fmt.Errorf("record %d out of range of %d records", i, count)
+	}
+	if err := r.seek(i, count); err != nil {
+		return err
+	}
+	r.next = i
+	return nil
+}
+
+// moves the reader to record i without keeping track of its number
+func (r *YCFileReader) seek(i int, count int) error {
+	if r.gz != nil {
+		if err := r.Rewind(); err != nil {
+			return err
+		}
+		return r.skipRecords(i)
+	}
+	if i == count {
+		info, err := r.ycf.file.Stat()
+		if err != nil {
+			return err
+		}
+		return r.seekFile(info.Size())
+	}
+
+	size, fixed := r.ycf.recordSize()
+	if !r.ycf.paged {
+		if !fixed {
+			if err := r.Rewind(); err != nil {
+				return err
+			}
+			return r.skipRecords(i)
+		}
+		return r.seekFile(int64(r.ycf.dataOffset() + i*size))
+	}
+
+	page, first := 0, 0 // page holding record i and the number of its first record
+	if fixed {
+		perPage := (PAGESIZE - PAGEHEADERSIZE) / size // the writer fills every page but the last
+		page, first = i/perPage, i/perPage*perPage
+	} else {
+		if err := r.loadPageStarts(); err != nil {
+			return err
+		}
+		page = sort.Search(len(r.pageStarts), func(p int) bool { return r.pageStarts[p] > i }) - 1
+		first = r.pageStarts[page]
+	}
+	if err := r.seekFile(int64(r.ycf.dataOffset() + page*PAGESIZE)); err != nil {
+		return err
+	}
+	if err := r.loadPage(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if !fixed {
+		return r.skipRecords(i - first)
+	}
+	if i-first >= r.pageRecords {
+		return fmt.Errorf("corrupt page %d, it holds %d records rather than record %d", page, r.pageRecords, i)
+	}
+	r.pageRecords -= i - first
+	_, err := r.page.Seek(int64((i-first)*size), io.SeekStart)
+	return err
+}
+
+// record i, the reader moves on to the record after it - the field filters aren't checked for a record asked for by number
+func (r *YCFileReader) ReadAt(i int) (YCFileRecord, error) {
+	if err := r.Seek(i); err != nil {
+		return YCFileRecord{}, err
+	}
+	if err := r.nextRecord(); err != nil {
+		return YCFileRecord{}, err
+	}
+	return r.decodeRecord()
+}
+
+// the records from record start to start+n, fewer if the file ends first - records failing a field filter are left out
+func (r *YCFileReader) ReadRange(start int, n int) ([]YCFileRecord, error) {
+	if err := r.Seek(start); err != nil {
+		return nil, err
+	}
+
+	records := []YCFileRecord{}
+	for {
+		record, err := r.ReadBefore(start + n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// moves the file to offset, dropping whatever was buffered or paged in
+func (r *YCFileReader) seekFile(offset int64) error {
+	r.pageRecords = 0
+	_, err := r.ycf.file.Seek(offset, io.SeekStart)
+	r.buffered.Reset(r.ycf.file)
+	return err
+}
+
+// skips n records without decoding them, the header counts them so they must all be there
+func (r *YCFileReader) skipRecords(n int) error {
+	for ; n > 0; n-- {
+		if err := r.nextRecord(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// reads the header of every page, once
+func (r *YCFileReader) loadPageStarts() error {
+	if r.pageStarts != nil {
+		return nil
+	}
+	info, err := r.ycf.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	pageStarts, next := []int{}, 0
+	header := make([]byte, PAGEHEADERSIZE)
+	for offset := int64(r.ycf.dataOffset()); offset+PAGESIZE <= info.Size(); offset += PAGESIZE {
+		if _, err := r.ycf.file.ReadAt(header, offset); err != nil {
+			return err
+		}
+		ph, err := ParsePageHeader(header)
+		if err != nil {
+			return err
+		}
+		pageStarts = append(pageStarts, next)
+		next += ph.RecordCount
+	}
+	r.pageStarts = pageStarts
+	return nil
+}
+
+// size of every record, fixed is false if they differ in size
+func (ycf *YCFile) recordSize() (size int, fixed bool) {
+	if ycf.hasNullBitmap() {
+		size += nullBitmapSize(len(ycf.headerFieldTypes))
+	}
+	for i := range ycf.headerFieldTypes {
+		fieldType := ycf.fieldType(i)
+		if fieldType == STRINGVAR {
+			return 0, false
+		}
+		size += FIELDTYPESTOLENGTH[fieldType]
+	}
+	return size, size > 0
+}
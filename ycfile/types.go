@@ -0,0 +1,129 @@
+package ycfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+/*** Field types - values are encoded natively, Read returns them as the Go type of their field and Write expects them as one ***/
+
+var FIELDTYPENAMES map[byte]string = map[byte]string{
+	STRINGSMALL: "ss",
+	STRINGMID:   "sm",
+	STRINGLONG:  "sl",
+	STRINGVAR:   "sv",
+	INT32:       "i32",
+	INT64:       "i64",
+	FLOAT64:     "f64",
+	BOOL:        "b",
+	TIMESTAMP:   "ts",
+}
+
+func validFieldType(fieldType byte) bool {
+	_, exists := FIELDTYPENAMES[fieldType]
+	return exists
+}
+
+// fixed width strings, cut short at the first PADDINGBYTE when read
+func isPaddedString(fieldType byte) bool {
+	return fieldType == STRINGSMALL || fieldType == STRINGMID || fieldType == STRINGLONG
+}
+
+func IsStringType(fieldType byte) bool {
+	fieldType &^= NULLABLE
+	return isPaddedString(fieldType) || fieldType == STRINGVAR
+}
+
+// stored in place of a NULL, so fixed width fields keep their width
+func nullPlaceholder(fieldType byte) []byte {
+	switch {
+	case fieldType == STRINGVAR:
+		return make([]byte, VARLENGTHPREFIX) // an empty value
+	case isPaddedString(fieldType):
+		return []byte(strings.Repeat(PADDINGBYTE, FIELDTYPESTOLENGTH[fieldType]))
+	}
+	return make([]byte, FIELDTYPESTOLENGTH[fieldType])
+}
+
+func encodeValue(fieldType byte, val interface{}) ([]byte, error) {
+	switch fieldType {
+	case STRINGSMALL, STRINGMID, STRINGLONG, STRINGVAR:
+		if s, ok := val.(string); ok {
+			encoded, err := castStringToFieldType(fieldType, s)
+			return []byte(encoded), err
+		}
+	case INT32:
+		if n, ok := toInt64(val); ok {
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("value %v out of range of %v", n, FIELDTYPENAMES[fieldType])
+			}
+			return binary.BigEndian.AppendUint32(nil, uint32(int32(n))), nil
+		}
+	case INT64:
+		if n, ok := toInt64(val); ok {
+			return binary.BigEndian.AppendUint64(nil, uint64(n)), nil
+		}
+	case FLOAT64:
+		switch f := val.(type) {
+		case float64:
+			return binary.BigEndian.AppendUint64(nil, math.Float64bits(f)), nil
+		case float32:
+			return binary.BigEndian.AppendUint64(nil, math.Float64bits(float64(f))), nil
+		}
+	case BOOL:
+		if b, ok := val.(bool); ok {
+			if b {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		}
+	case TIMESTAMP:
+		if t, ok := val.(time.Time); ok {
+			return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano())), nil
+		}
+	}
+	return nil, fmt.Errorf("value %v of type %T can't be stored as %v", val, val, FIELDTYPENAMES[fieldType])
+}
+
+// converts the raw bytes of a field, padding stripped, to the Go type of its field
+func DecodeValue(fieldType byte, raw []byte) (interface{}, error) {
+	fieldType &^= NULLABLE
+	if IsStringType(fieldType) {
+		return string(raw), nil
+	}
+	if length, exists := FIELDTYPESTOLENGTH[fieldType]; !exists || len(raw) != length {
+		return nil, fmt.Errorf("%d bytes can't be decoded as field type %d", len(raw), fieldType)
+	}
+
+	switch fieldType {
+	case INT32:
+		return int32(binary.BigEndian.Uint32(raw)), nil
+	case INT64:
+		return int64(binary.BigEndian.Uint64(raw)), nil
+	case FLOAT64:
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+	case BOOL:
+		if raw[0] > 1 {
+			return nil, fmt.Errorf("invalid bool byte %d", raw[0])
+		}
+		return raw[0] == 1, nil
+	case TIMESTAMP:
+		return time.Unix(0, int64(binary.BigEndian.Uint64(raw))).UTC(), nil
+	}
+	return nil, fmt.Errorf("invalid fieldtype %d", fieldType)
+}
+
+func toInt64(val interface{}) (int64, bool) {
+	switch n := val.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
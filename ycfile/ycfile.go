@@ -1,11 +1,15 @@
 package ycfile
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -16,23 +20,45 @@ const (
 	STRINGSMALL = iota
 	STRINGMID
 	STRINGLONG
+	STRINGVAR // variable length, stored as a 4 byte big endian length followed by the value itself
+	INT32
+	INT64
+	FLOAT64
+	BOOL
+	TIMESTAMP // nanoseconds since the unix epoch, read back in UTC
 )
+const VARLENGTHPREFIX = 4
+const NULLABLE = 0x80 // flag on the field type byte of a column that can hold NULLs
 
-var MAGICNUMBER []byte = []byte{0x31, 0x08, 0x19, 0x98}
+var MAGICNUMBER []byte = []byte{0x31, 0x08, 0x19, 0x99}
+var LEGACYMAGICNUMBER []byte = []byte{0x31, 0x08, 0x19, 0x98} // files from before pages, records stored contiguously after the header - still read and appended to
+var GZIPMAGIC []byte = []byte{0x1f, 0x8b}
 
-var FIELDTYPESTOLENGTH map[byte]int = map[byte]int{
+var FIELDTYPESTOLENGTH map[byte]int = map[byte]int{ // fixed width types, strings are padded with PADDINGBYTE so they can't contain it
 	0: 16, // "ss" string small,
 	1: 32, // "sm" string medium,
 	2: 64, // "sl" string large,
+	4: 4,  // "i32" big endian two's complement
+	5: 8,  // "i64"
+	6: 8,  // "f64" big endian IEEE 754 bits
+	7: 1,  // "b" 0 or 1
+	8: 8,  // "ts" as an i64
 }
 
 type YCFileRecord struct {
-	Data []StringPair
+	Data []FieldPair
 }
 
-type StringPair struct {
+type FieldPair struct {
 	Key string
-	Val string
+	Val interface{} // string, int32, int64, float64, bool or time.Time depending on the field's type, nil for NULL
+}
+
+type StringPair = FieldPair // from when every value was a string
+
+type FieldFilter struct { // a record passes if Match accepts the raw bytes of its Field, padding stripped
+	Field string
+	Match func(val []byte) bool
 }
 
 type YCFile struct {
@@ -42,24 +68,53 @@ type YCFile struct {
 	headerFieldCount  []byte
 	headerFieldTypes  []byte
 	headerFields      []byte
+	paged             bool // false for legacy files
 }
 
 type YCFileWriter struct {
-	ycf *YCFile
+	ycf        *YCFile
+	page       int64 // offset of the last page, 0 if the file has none yet
+	pageHeader PageHeader
 }
 
 type YCFileReader struct {
-	ycf *YCFile
+	ycf      *YCFile
+	src      io.Reader     // records are read from here, the buffered file or its decompressed contents
+	buffered *bufio.Reader // nil if the file is gzipped
+	gz       *gzip.Reader  // nil unless the file is gzipped
+	fields   []string      // column names, decoded from the header once
+	selected []bool        // fields Read decodes, every field if nil
+	filters  []fieldFilter
+	nulls    []byte // null bitmap of the last record read, nil if the file has no nullable columns
+	buf      []byte // bytes of the last record read
+	offsets  []int  // the value of field i is buf[offsets[i]:offsets[i+1]], padding included
+
+	pageBuf     []byte       // page being read, unused for legacy files
+	page        bytes.Reader // records of pageBuf
+	pageRecords int          // records of pageBuf Read hasn't reached yet
+	pageFlags   byte         // of pageBuf
+	pageStarts  []int        // number of the first record of every page, loaded by Seek for files with variable length fields
+	next        int          // number of the record nextRecord reads
+}
+
+type fieldFilter struct {
+	idx   int // field the filter checks
+	match func(val []byte) bool
 }
 
 // - De-facto header:
-// 	   - magic number 4 bytes 0x31081998
+// 	   - magic number 4 bytes 0x31081999 (0x31081998 for legacy files)
 //     - Reserve 8 bytes at the start for the number of records filled so far
 //     - 1 byte for number of fields
-//     - Next 1 byte * number of field bits for indicating their type (00 ss, 01 sm, 10 sl) (not very efficient)
+//     - Next 1 byte * number of field bits for indicating their type (0 ss, 1 sm, 2 sl, 3 sv, 4 i32, 5 i64, 6 f64, 7 b, 8 ts) (not very efficient)
 //     - First record indicates the column names, all of type sl (always)
 //     - next 'n' records are the actual tuples, the records are of types indicated by the fieldtype bytes in the same order
-// Note: I realized later this is the PAGE header so no point of reserving 8 bytes for number of records
+//     - sv (variable length) values are prefixed with their 4 byte length instead of being padded, so records differ in size
+//     - numbers, bools and timestamps are stored in binary rather than as text
+//     - the high bit (0x80) of a field type byte marks a nullable column, records of a file with any start with a bitmap of their NULL fields
+//       NULL fields still take the space of an empty value, so fixed width records keep a fixed size
+//     - the header is padded with zeroes to a whole number of pages, records are stored in the pages after it (see page.go)
+//       legacy files have no padding or pages, their records follow the header back to back
 
 func CreateYCFile(path string, fields []string, fieldTypes []byte) error {
 	if len(fields) > MAXFIELDS {
@@ -86,8 +141,7 @@ func CreateYCFile(path string, fields []string, fieldTypes []byte) error {
 		b = append(b, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}...) // number of records so far
 		b = append(b, byte(len(fields)))                                         // number of fields
 		for _, fieldType := range fieldTypes {                                   // verifying field types
-			_, exists := FIELDTYPESTOLENGTH[fieldType]
-			if !exists {
+			if !validFieldType(fieldType &^ NULLABLE) {
 				return fmt.Errorf("invalid fieldtype")
 			}
 		}
@@ -101,6 +155,8 @@ func CreateYCFile(path string, fields []string, fieldTypes []byte) error {
 			b = append(b, []byte(fieldAsStringLong)...)
 		}
 
+		b = append(b, make([]byte, pagesSize(len(b))-len(b))...) // padding, pages start on a multiple of PAGESIZE
+
 		_, err = f.Write(b)
 		if err != nil {
 			return err
@@ -132,31 +188,44 @@ func NewYCFile(path string) (*YCFile, error) {
 	}
 	ycf.file = f
 
+	if err := ycf.readHeader(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &ycf, nil
+}
+
+// reads the header fields from r, which must be at the start of the file
+func (ycf *YCFile) readHeader(r io.Reader) error {
 	headerMagicNumber := make([]byte, 4)
 	headerRecordCount := make([]byte, 8)
 	headerFieldCount := make([]byte, 1)
-	if _, err := f.Read(headerMagicNumber); err != nil {
-		return nil, err
+	if _, err := io.ReadFull(r, headerMagicNumber); err != nil {
+		return err
 	}
-	if _, err := f.Read(headerRecordCount); err != nil {
-		return nil, err
+	if !bytes.Equal(headerMagicNumber, MAGICNUMBER) && !bytes.Equal(headerMagicNumber, LEGACYMAGICNUMBER) {
+		return fmt.Errorf("not a valid yc file, magic number %d", headerMagicNumber)
 	}
-	if _, err := f.Read(headerFieldCount); err != nil {
-		return nil, err
+	if _, err := io.ReadFull(r, headerRecordCount); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, headerFieldCount); err != nil {
+		return err
 	}
 
 	headerFieldTypes := make([]byte, headerFieldCount[0])
 	headerFields := make([]byte, FIELDTYPESTOLENGTH[STRINGLONG]*int(headerFieldCount[0]))
-	if _, err := f.Read(headerFieldTypes); err != nil {
-		return nil, err
+	if _, err := io.ReadFull(r, headerFieldTypes); err != nil {
+		return err
 	}
-	if _, err := f.Read(headerFields); err != nil {
-		return nil, err
+	if _, err := io.ReadFull(r, headerFields); err != nil {
+		return err
 	}
 
 	ycf.headerMagicNumber, ycf.headerRecordCount, ycf.headerFieldCount, ycf.headerFieldTypes, ycf.headerFields = headerMagicNumber, headerRecordCount, headerFieldCount, headerFieldTypes, headerFields
-
-	return &ycf, nil
+	ycf.paged = bytes.Equal(headerMagicNumber, MAGICNUMBER)
+	return nil
 }
 
 // returns a writer if path has valid yc file
@@ -168,21 +237,72 @@ func NewYCFileWriter(path string) (*YCFileWriter, error) {
 	}
 
 	w.ycf = ycf
+	if ycf.paged {
+		if err := w.findLastPage(); err != nil {
+			ycf.file.Close()
+			return nil, err
+		}
+	}
 	return &w, nil
 }
 
-// returns a writer if path has valid yc file
+// returns a reader if path has valid yc file, gzipped files (by magic bytes or a .gz extension) are decompressed on the fly
 func NewYCFileReader(path string) (*YCFileReader, error) {
-	r := YCFileReader{}
-	ycf, err := NewYCFile(path)
+	r := YCFileReader{ycf: &YCFile{}}
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	r.ycf.file = f
+
+	if err := r.openSource(path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := r.ycf.readHeader(r.src); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, r.src, int64(r.ycf.dataOffset()-r.ycf.computeSizeOfHeader())); err != nil { // header padding
+		r.Close()
+		return nil, err
+	}
+
+	columnNamesLength := FIELDTYPESTOLENGTH[STRINGLONG]
+	for i := 0; i < int(r.ycf.headerFieldCount[0]); i++ {
+		curColumnName := r.ycf.headerFields[i*columnNamesLength : (i+1)*columnNamesLength]
+		r.fields = append(r.fields, strings.Split(string(curColumnName), PADDINGBYTE)[0])
+	}
 
-	r.ycf = ycf
 	return &r, nil
 }
 
+// sets src to read from the start of the file, through a new decompression stream if it is gzipped
+func (r *YCFileReader) openSource(path string) error {
+	f := r.ycf.file
+	magic := make([]byte, len(GZIPMAGIC))
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(magic[:n], GZIPMAGIC) && filepath.Ext(path) != ".gz" {
+		r.buffered = bufio.NewReader(f) // records with variable length fields are read a field at a time
+		r.src = r.buffered
+		return nil
+	}
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	r.src, r.gz = gz, gz
+	return nil
+}
+
 func (w *YCFileWriter) Write(record YCFileRecord) error { // assuming the file is already a valid YCFile
 	ycf := w.ycf
 
@@ -192,16 +312,35 @@ func (w *YCFileWriter) Write(record YCFileRecord) error { // assuming the file i
 	}
 
 	buf := []byte{} // write to a buffer, then write to the file
+	var nulls []byte
+	if ycf.hasNullBitmap() {
+		nulls = make([]byte, nullBitmapSize(len(record.Data)))
+		buf = append(buf, nulls...) // filled in below
+	}
 	for i, pair := range record.Data {
-		fieldType := ycf.headerFieldTypes[i]
-		dataAsFieldType, err := castStringToFieldType(fieldType, pair.Val)
+		fieldType := ycf.fieldType(i)
+		if pair.Val == nil {
+			if ycf.headerFieldTypes[i]&NULLABLE == 0 {
+				return fmt.Errorf("field %v: NULL in a column that isn't nullable", pair.Key)
+			}
+			nulls[i/8] |= 1 << (i % 8)
+			buf = append(buf, nullPlaceholder(fieldType)...)
+			continue
+		}
+
+		dataAsFieldType, err := encodeValue(fieldType, pair.Val)
 		if err != nil {
-			return err
+			return fmt.Errorf("field %v: %w", pair.Key, err)
 		}
 
-		buf = append(buf, []byte(dataAsFieldType)...)
+		buf = append(buf, dataAsFieldType...)
+	}
+	copy(buf, nulls)
+	if ycf.paged {
+		err = w.writeToPage(buf)
+	} else {
+		_, err = ycf.file.Write(buf)
 	}
-	_, err = ycf.file.Write(buf)
 	if err != nil {
 		return err
 	}
@@ -238,39 +377,222 @@ func (w *YCFileWriter) Close() error { // assuming the file is already a valid Y
 }
 
 func (r *YCFileReader) Read() (YCFileRecord, error) { // assuming header is already read and we are at correct offset always
-	ycf := r.ycf
-	record := YCFileRecord{}
+	return r.ReadBefore(math.MaxInt)
+}
 
-	sizeOfRecord := ycf.computeSizeOfARecord()
-	buf := make([]byte, sizeOfRecord)
-	_, err := ycf.file.Read(buf)
-	if err != nil {
-		return YCFileRecord{}, err
+// the next record passing the field filters, up to record end - io.EOF if there is none, the reader is then at end or the end of the file
+// records failing a filter are skipped without decoding their fields
+func (r *YCFileReader) ReadBefore(end int) (YCFileRecord, error) {
+	for r.next < end {
+		if err := r.nextRecord(); err != nil {
+			return YCFileRecord{}, err
+		}
+		if r.matchFilters() {
+			return r.decodeRecord()
+		}
 	}
+	return YCFileRecord{}, io.EOF
+}
 
-	offset := 0
-	for i := 0; i < int(ycf.headerFieldCount[0]); i++ {
-		// first convert key to string
-		fieldType := ycf.headerFieldTypes[i]
-		size := FIELDTYPESTOLENGTH[fieldType]
-		val := buf[offset : offset+size]
-		valToString := strings.Split(string(val), PADDINGBYTE)[0]
-		offset += size
+// reads the bytes of the next record without decoding them, from its page for paged files
+func (r *YCFileReader) nextRecord() error {
+	src := r.src
+	if r.ycf.paged {
+		var err error
+		if src, err = r.nextPageRecord(); err != nil {
+			return err
+		}
+	}
 
-		// then get column name to string
-		columnNamesLength := FIELDTYPESTOLENGTH[STRINGLONG] // all are of type STRINGLONG
-		curColumnName := ycf.headerFields[i*columnNamesLength : (i+1)*columnNamesLength]
-		columnNameToString := strings.Split(string(curColumnName), PADDINGBYTE)[0]
+	err := r.readRecord(src)
+	if err == io.EOF && r.ycf.paged { // a page ended before all of its records
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		r.next++
+	}
+	return err
+}
 
-		// join both as a pair
-		dataPair := StringPair{Key: columnNameToString, Val: string(valToString)}
+// checks the last record read against the filters, NULLs fail every filter
+func (r *YCFileReader) matchFilters() bool {
+	for _, filter := range r.filters {
+		if r.isNull(filter.idx) || !filter.match(r.value(filter.idx)) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodes the selected fields of the last record read
+func (r *YCFileReader) decodeRecord() (YCFileRecord, error) {
+	record := YCFileRecord{}
+	for i := range r.fields {
+		if r.selected != nil && !r.selected[i] { // skipped without decoding
+			continue
+		}
+
+		// decode the value and pair it with its column name
+		var val interface{}
+		if !r.isNull(i) {
+			var err error
+			val, err = DecodeValue(r.ycf.fieldType(i), r.value(i))
+			if err != nil {
+				return YCFileRecord{}, fmt.Errorf("field %v: %w", r.fields[i], err)
+			}
+		}
+		dataPair := FieldPair{Key: r.fields[i], Val: val}
 		record.Data = append(record.Data, dataPair)
 	}
 
 	return record, nil
 }
 
+// reads the bytes of the next record of src into buf a field at a time, the length of a variable length field is only known once its prefix is read
+func (r *YCFileReader) readRecord(src io.Reader) error {
+	fieldCount := len(r.ycf.headerFieldTypes)
+	if r.offsets == nil {
+		r.offsets = make([]int, fieldCount+1)
+		if r.ycf.hasNullBitmap() {
+			r.nulls = make([]byte, nullBitmapSize(fieldCount))
+		}
+	}
+
+	started := false // whether any byte of the record has been read
+	if r.nulls != nil {
+		if err := readRecordBytes(src, r.nulls, started); err != nil {
+			return err
+		}
+		started = true
+	}
+
+	buf := r.buf[:0]
+	for i := 0; i < fieldCount; i++ {
+		fieldType := r.ycf.fieldType(i)
+		r.offsets[i] = len(buf)
+		size := FIELDTYPESTOLENGTH[fieldType]
+		if fieldType == STRINGVAR {
+			prefix := make([]byte, VARLENGTHPREFIX)
+			if err := readRecordBytes(src, prefix, started); err != nil {
+				return err
+			}
+			size, started = int(binary.BigEndian.Uint32(prefix)), true
+		}
+
+		start := len(buf)
+		buf = append(buf, make([]byte, size)...)
+		if err := readRecordBytes(src, buf[start:], started); err != nil {
+			return err
+		}
+		started = started || size > 0
+	}
+	r.offsets[fieldCount] = len(buf)
+	r.buf = buf
+	return nil
+}
+
+// io.EOF is only returned if the record has no bytes at all, a file ending within a record is corrupt
+func readRecordBytes(src io.Reader, b []byte, started bool) error {
+	if len(b) == 0 {
+		return nil
+	}
+	_, err := io.ReadFull(src, b)
+	if err == io.EOF && started {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (r *YCFileReader) isNull(i int) bool {
+	return r.nulls != nil && r.nulls[i/8]&(1<<(i%8)) != 0
+}
+
+// value of field i of the last record read, padding stripped
+func (r *YCFileReader) value(i int) []byte {
+	val := r.buf[r.offsets[i]:r.offsets[i+1]]
+	if !isPaddedString(r.ycf.fieldType(i)) {
+		return val
+	}
+	if end := bytes.Index(val, []byte(PADDINGBYTE)); end != -1 {
+		val = val[:end]
+	}
+	return val
+}
+
+func (r *YCFileReader) Rewind() error { // the next Read returns the first record again
+	r.pageRecords, r.next = 0, 0
+	if r.gz == nil {
+		return r.seekFile(int64(r.ycf.dataOffset()))
+	}
+
+	/* A gzip stream can't seek, decompress it again from the start and skip the header */
+	if _, err := r.ycf.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := r.gz.Reset(bufio.NewReader(r.ycf.file)); err != nil {
+		return err
+	}
+	_, err := io.CopyN(io.Discard, r.gz, int64(r.ycf.dataOffset()))
+	return err
+}
+
+func (r *YCFileReader) Fields() []string { // column names, in the order of the fields of a record
+	return append([]string{}, r.fields...)
+}
+
+// restricts the records returned by Read to the given fields, still in file order - the other fields are skipped without being decoded, nil selects every field again
+func (r *YCFileReader) SelectFields(fields []string) error {
+	if fields == nil {
+		r.selected = nil
+		return nil
+	}
+
+	selected := make([]bool, len(r.fields))
+	for _, field := range fields {
+		i := 0
+		for i < len(r.fields) && r.fields[i] != field {
+			i++
+		}
+		if i == len(r.fields) {
+			return fmt.Errorf("no field %v to select in %v", field, r.fields)
+		}
+		selected[i] = true
+	}
+	r.selected = selected
+	return nil
+}
+
+// has Read check every record against the filters before decoding any field, records failing one are skipped - nil removes the filters
+// Match gets the raw bytes of the field, which DecodeValue converts for types other than strings
+// Match is handed bytes of the reader's own buffer, which it must not keep
+func (r *YCFileReader) SetFieldFilters(filters []FieldFilter) error {
+	r.filters = nil
+	for _, filter := range filters {
+		i := 0
+		for i < len(r.fields) && r.fields[i] != filter.Field {
+			i++
+		}
+		if i == len(r.fields) {
+			r.filters = nil
+			return fmt.Errorf("no field %v to filter in %v", filter.Field, r.fields)
+		}
+		r.filters = append(r.filters, fieldFilter{idx: i, match: filter.Match})
+	}
+	return nil
+}
+
+func (r *YCFileReader) FieldTypes() []byte { // as in the header, NULLABLE flags included
+	return bytes.Clone(r.ycf.headerFieldTypes)
+}
+
+func (r *YCFileReader) RecordCount() int { // number of records written to the file when it was opened
+	return int(binary.BigEndian.Uint64(r.ycf.headerRecordCount))
+}
+
 func (w *YCFileReader) Close() error { // assuming the file is already a valid YCFile
+	if w.gz != nil {
+		w.gz.Close()
+	}
 	if err := w.ycf.file.Close(); err != nil {
 		return err
 	}
@@ -292,7 +614,19 @@ func fileExists(path string) (bool, error) {
 }
 
 func castStringToFieldType(fieldType byte, s string) (string, error) {
+	if fieldType == STRINGVAR {
+		if uint64(len(s)) > math.MaxUint32 {
+			return "", fmt.Errorf("string of length %d longer than a variable length field can hold", len(s))
+		}
+		prefix := make([]byte, VARLENGTHPREFIX)
+		binary.BigEndian.PutUint32(prefix, uint32(len(s)))
+		return string(prefix) + s, nil
+	}
+
 	fieldTypeLen := FIELDTYPESTOLENGTH[fieldType]
+	if strings.Contains(s, PADDINGBYTE) { // would be cut short when read
+		return "", fmt.Errorf("string %q contains the padding byte, only variable length fields can hold it", s)
+	}
 	if len(s) > fieldTypeLen {
 		return "", fmt.Errorf("string %s length %d longer than type %d", s, len(s), fieldType)
 	}
@@ -320,7 +654,7 @@ func validateYCFile(path string) error {
 		return err
 	}
 
-	if !bytes.Equal(headerMagicNumber, MAGICNUMBER) {
+	if !bytes.Equal(headerMagicNumber, MAGICNUMBER) && !bytes.Equal(headerMagicNumber, LEGACYMAGICNUMBER) {
 		return fmt.Errorf("not a valid yc file, magic number %d", headerMagicNumber)
 	}
 
@@ -353,16 +687,36 @@ func (w *YCFileWriter) validateRecordWithFile(record YCFileRecord) error {
 	return nil
 }
 
-func (ycf *YCFile) computeSizeOfARecord() int {
-	sizeOfRecord := 0                                 // compute size of a single record
-	for _, fieldTypes := range ycf.headerFieldTypes { // we assume all field types are valid
-		sizeOfRecord += FIELDTYPESTOLENGTH[fieldTypes]
+// type of field i, without the NULLABLE flag
+func (ycf *YCFile) fieldType(i int) byte {
+	return ycf.headerFieldTypes[i] &^ NULLABLE
+}
+
+// records of files with nullable columns start with a bitmap, bit i is set if field i is NULL
+func (ycf *YCFile) hasNullBitmap() bool {
+	for _, fieldType := range ycf.headerFieldTypes {
+		if fieldType&NULLABLE != 0 {
+			return true
+		}
 	}
-	return sizeOfRecord
+	return false
 }
 
-// func (ycf *YCFile) computePageSize() int { // returns page size in bytes: header + records
-// 	headerLength := ycf.getHeaderLength()
-// 	recordCount := int(binary.BigEndian.Uint64(ycf.headerRecordCount)) // number of records on a page won't
+func nullBitmapSize(fieldCount int) int {
+	return (fieldCount + 7) / 8
+}
+
+func (ycf *YCFile) computeSizeOfHeader() int { // padding excluded
+	return len(ycf.headerMagicNumber) + len(ycf.headerRecordCount) + len(ycf.headerFieldCount) + len(ycf.headerFieldTypes) + len(ycf.headerFields)
+}
 
-// }
+func (ycf *YCFile) dataOffset() int { // records start here, right after the header of legacy files
+	if !ycf.paged {
+		return ycf.computeSizeOfHeader()
+	}
+	return pagesSize(ycf.computeSizeOfHeader())
+}
+
+func pagesSize(n int) int { // size of the pages n bytes take up
+	return (n + PAGESIZE - 1) / PAGESIZE * PAGESIZE
+}
@@ -1,7 +1,17 @@
 package ycfile
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -68,3 +78,514 @@ func TestReadWrite(t *testing.T) {
 	require.Equal(t, r2, r2Read)
 	require.Equal(t, r3, r3Read)
 }
+
+func TestSelectFields(t *testing.T) {
+	fields := []string{"movieId", "title", "genres"}
+	path := t.TempDir() + "/movies"
+	require.NoError(t, CreateYCFile(path, fields, []byte{0, 2, 1}))
+
+	writer, err := NewYCFileWriter(path)
+	require.NoError(t, err)
+	defer writer.Close()
+	require.NoError(t, writer.Write(YCFileRecord{Data: []StringPair{{Key: "movieId", Val: "123"}, {Key: "title", Val: "Love You Zindagi"}, {Key: "genres", Val: "Romance"}}}))
+	require.NoError(t, writer.Write(YCFileRecord{Data: []StringPair{{Key: "movieId", Val: "124"}, {Key: "title", Val: "Sholay"}, {Key: "genres", Val: "Comedy | Romance"}}}))
+
+	reader, err := NewYCFileReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.NoError(t, reader.SelectFields([]string{"genres", "movieId"}))
+	record, err := reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, YCFileRecord{Data: []StringPair{{Key: "movieId", Val: "123"}, {Key: "genres", Val: "Romance"}}}, record)
+
+	require.NoError(t, reader.SelectFields(nil))
+	record, err = reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, YCFileRecord{Data: []StringPair{{Key: "movieId", Val: "124"}, {Key: "title", Val: "Sholay"}, {Key: "genres", Val: "Comedy | Romance"}}}, record)
+
+	require.ErrorContains(t, reader.SelectFields([]string{"rating"}), "no field rating to select")
+	require.Equal(t, fields, reader.Fields())
+}
+
+func TestFieldFilters(t *testing.T) {
+	path := t.TempDir() + "/movies"
+	require.NoError(t, CreateYCFile(path, []string{"movieId", "title"}, []byte{0, 2}))
+
+	writer, err := NewYCFileWriter(path)
+	require.NoError(t, err)
+	defer writer.Close()
+	for _, title := range []string{"Sholay", "Chole", "Sholay"} {
+		require.NoError(t, writer.Write(YCFileRecord{Data: []StringPair{{Key: "movieId", Val: "1"}, {Key: "title", Val: title}}}))
+	}
+
+	reader, err := NewYCFileReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.ErrorContains(t, reader.SetFieldFilters([]FieldFilter{{Field: "genres"}}), "no field genres to filter")
+
+	require.NoError(t, reader.SetFieldFilters([]FieldFilter{{Field: "title", Match: func(val []byte) bool { return string(val) == "Sholay" }}}))
+	require.NoError(t, reader.SelectFields([]string{"movieId"}))
+	for _, expectedErr := range []error{nil, nil, io.EOF} {
+		record, err := reader.Read()
+		require.Equal(t, expectedErr, err)
+		if err == nil {
+			require.Equal(t, YCFileRecord{Data: []StringPair{{Key: "movieId", Val: "1"}}}, record)
+		}
+	}
+}
+
+func TestVariableLengthStrings(t *testing.T) {
+	path := t.TempDir() + "/movies"
+	require.NoError(t, CreateYCFile(path, []string{"movieId", "title", "genres"}, []byte{STRINGSMALL, STRINGVAR, STRINGMID}))
+
+	records := []YCFileRecord{}
+	for _, title := range []string{
+		"Dr. Strangelove or: How I Learned to Stop Worrying and Love the Bomb (1964)",
+		"Line one\nline two\n",
+		"",
+		"Amélie (Le fabuleux destin d'Amélie Poulain) (2001) - a title well over sixty four bytes",
+	} {
+		records = append(records, YCFileRecord{Data: []StringPair{{Key: "movieId", Val: "1"}, {Key: "title", Val: title}, {Key: "genres", Val: "Comedy"}}})
+	}
+
+	writer, err := NewYCFileWriter(path)
+	require.NoError(t, err)
+	defer writer.Close()
+	for _, record := range records {
+		require.NoError(t, writer.Write(record))
+	}
+	err = writer.Write(YCFileRecord{Data: []StringPair{{Key: "movieId", Val: "1\n"}, {Key: "title", Val: ""}, {Key: "genres", Val: ""}}})
+	require.ErrorContains(t, err, "contains the padding byte")
+
+	reader, err := NewYCFileReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, len(records), reader.RecordCount())
+	for run := 0; run < 2; run++ {
+		for _, expected := range records {
+			record, err := reader.Read()
+			require.NoError(t, err)
+			require.Equal(t, expected, record)
+		}
+		_, err = reader.Read()
+		require.Equal(t, io.EOF, err)
+		require.NoError(t, reader.Rewind())
+	}
+
+	require.NoError(t, reader.SetFieldFilters([]FieldFilter{{Field: "title", Match: func(val []byte) bool { return string(val) == "Line one\nline two\n" }}}))
+	require.NoError(t, reader.SelectFields([]string{"title", "genres"}))
+	for _, expectedErr := range []error{nil, io.EOF} {
+		record, err := reader.Read()
+		require.Equal(t, expectedErr, err)
+		if err == nil {
+			require.Equal(t, YCFileRecord{Data: []StringPair{{Key: "title", Val: "Line one\nline two\n"}, {Key: "genres", Val: "Comedy"}}}, record)
+		}
+	}
+}
+
+func TestTypedFields(t *testing.T) {
+	path := t.TempDir() + "/ratings"
+	fields := []string{"userId", "movieId", "rating", "liked", "timestamp", "comment"}
+	require.NoError(t, CreateYCFile(path, fields, []byte{INT64, INT32, FLOAT64, BOOL, TIMESTAMP, STRINGVAR}))
+
+	record := func(userId int64, movieId int32, rating float64, liked bool, timestamp time.Time) YCFileRecord {
+		return YCFileRecord{Data: []FieldPair{{Key: "userId", Val: userId}, {Key: "movieId", Val: movieId}, {Key: "rating", Val: rating}, {Key: "liked", Val: liked}, {Key: "timestamp", Val: timestamp}, {Key: "comment", Val: "ok"}}}
+	}
+	records := []YCFileRecord{
+		record(1, 1, 4.5, true, time.Date(2019, 3, 1, 12, 30, 0, 123, time.UTC)),
+		record(math.MaxInt64, math.MinInt32, -0.25, false, time.Unix(0, 0).UTC()),
+		record(-7, math.MaxInt32, math.Inf(1), true, time.Date(1969, 7, 20, 20, 17, 0, 0, time.UTC)),
+	}
+
+	writer, err := NewYCFileWriter(path)
+	require.NoError(t, err)
+	defer writer.Close()
+	for _, r := range records {
+		require.NoError(t, writer.Write(r))
+	}
+
+	tc := []struct {
+		name  string
+		field int
+		val   interface{}
+		err   string
+	}{
+		{name: "int32 out of range", field: 1, val: int64(math.MaxInt32 + 1), err: "field movieId: value 2147483648 out of range of i32"},
+		{name: "string as a number", field: 2, val: "4.5", err: "field rating: value 4.5 of type string can't be stored as f64"},
+		{name: "number as a string", field: 5, val: 5, err: "field comment: value 5 of type int can't be stored as sv"},
+		{name: "timestamp as a number", field: 4, val: int64(0), err: "field timestamp: value 0 of type int64 can't be stored as ts"},
+	}
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			bad := record(1, 1, 1, true, time.Now())
+			bad.Data[test.field].Val = test.val
+			require.EqualError(t, writer.Write(bad), test.err)
+		})
+	}
+
+	reader, err := NewYCFileReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, []byte{INT64, INT32, FLOAT64, BOOL, TIMESTAMP, STRINGVAR}, reader.FieldTypes())
+	for _, expected := range records {
+		r, err := reader.Read()
+		require.NoError(t, err)
+		require.Equal(t, expected, r)
+	}
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+
+	require.ErrorContains(t, CreateYCFile(path, []string{"id"}, []byte{9}), "invalid fieldtype")
+}
+
+func TestNullableFields(t *testing.T) {
+	path := t.TempDir() + "/ratings"
+	fieldTypes := []byte{INT64, FLOAT64 | NULLABLE, STRINGVAR | NULLABLE, STRINGSMALL | NULLABLE, INT32, BOOL, BOOL, BOOL, BOOL | NULLABLE}
+	fields := []string{"userId", "rating", "title", "genre", "movieId", "a", "b", "c", "d"} // nine fields, the bitmap takes two bytes
+	require.NoError(t, CreateYCFile(path, fields, fieldTypes))
+
+	record := func(vals ...interface{}) YCFileRecord {
+		r := YCFileRecord{}
+		for i, val := range vals {
+			r.Data = append(r.Data, FieldPair{Key: fields[i], Val: val})
+		}
+		return r
+	}
+	records := []YCFileRecord{
+		record(int64(1), 4.5, "Heat", "", int32(1), true, false, true, false),
+		record(int64(2), nil, nil, nil, int32(2), true, false, true, nil),
+		record(int64(3), 0.0, "", nil, int32(3), false, false, false, true),
+	}
+
+	writer, err := NewYCFileWriter(path)
+	require.NoError(t, err)
+	defer writer.Close()
+	for _, r := range records {
+		require.NoError(t, writer.Write(r))
+	}
+	require.EqualError(t, writer.Write(record(nil, 1.0, "", "", int32(4), true, true, true, true)), "field userId: NULL in a column that isn't nullable")
+
+	reader, err := NewYCFileReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, fieldTypes, reader.FieldTypes())
+	for _, expected := range records {
+		r, err := reader.Read()
+		require.NoError(t, err)
+		require.Equal(t, expected, r)
+	}
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+
+	// NULLs fail every filter, empty strings don't
+	require.NoError(t, reader.Rewind())
+	require.NoError(t, reader.SetFieldFilters([]FieldFilter{{Field: "title", Match: func(val []byte) bool { return len(val) == 0 }}}))
+	for _, expectedErr := range []error{nil, io.EOF} {
+		r, err := reader.Read()
+		require.Equal(t, expectedErr, err)
+		if err == nil {
+			require.Equal(t, records[2], r)
+		}
+	}
+}
+
+func TestPages(t *testing.T) {
+	path := t.TempDir() + "/movies"
+	fields := []string{"movieId", "title"}
+	require.NoError(t, CreateYCFile(path, fields, []byte{INT64, STRINGVAR}))
+	record := func(i int) YCFileRecord {
+		return YCFileRecord{Data: []FieldPair{{Key: "movieId", Val: int64(i)}, {Key: "title", Val: fmt.Sprintf("%0100d", i)}}}
+	}
+
+	// records of 112 bytes, the 8184 bytes after a page header hold 73 of them
+	records := []YCFileRecord{}
+	for _, batch := range [][2]int{{0, 150}, {150, 200}} { // the second writer appends to the last page of the first
+		writer, err := NewYCFileWriter(path)
+		require.NoError(t, err)
+		for i := batch[0]; i < batch[1]; i++ {
+			require.NoError(t, writer.Write(record(i)))
+			records = append(records, record(i))
+		}
+		require.NoError(t, writer.Close())
+	}
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(PAGESIZE*4), info.Size()) // a page of header and three of records
+
+	reader, err := NewYCFileReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, 200, reader.RecordCount())
+	for _, expected := range records {
+		r, err := reader.Read()
+		require.NoError(t, err)
+		require.Equal(t, expected, r)
+	}
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+
+	// a page at a time, Read continues after the last page read
+	require.NoError(t, reader.Rewind())
+	_, err = reader.Read()
+	require.NoError(t, err)
+	page, err := reader.ReadPage()
+	require.NoError(t, err)
+	ph, err := ParsePageHeader(page)
+	require.NoError(t, err)
+	require.Equal(t, PageHeader{RecordCount: 73, FreeSpace: 8}, ph)
+	pageRecords, err := reader.PageRecords(page)
+	require.NoError(t, err)
+	require.Equal(t, records[73:146], pageRecords)
+	r, err := reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, records[146], r)
+	r, err = reader.ReadBefore(148)
+	require.NoError(t, err)
+	require.Equal(t, records[147], r)
+	_, err = reader.ReadBefore(148)
+	require.Equal(t, io.EOF, err)
+
+	require.NoError(t, reader.SelectFields([]string{"movieId"}))
+	require.NoError(t, reader.SetFieldFilters([]FieldFilter{{Field: "title", Match: func(val []byte) bool { return string(val) == fmt.Sprintf("%0100d", 120) }}}))
+	pageRecords, err = reader.PageRecords(page)
+	require.NoError(t, err)
+	require.Equal(t, []YCFileRecord{{Data: []FieldPair{{Key: "movieId", Val: int64(120)}}}}, pageRecords)
+}
+
+func TestOverflowPages(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "movies")
+	require.NoError(t, CreateYCFile(path, []string{"movieId", "title"}, []byte{INT64, STRINGVAR}))
+	record := func(i int, titleSize int) YCFileRecord {
+		return YCFileRecord{Data: []FieldPair{{Key: "movieId", Val: int64(i)}, {Key: "title", Val: strings.Repeat(fmt.Sprint(i), titleSize)}}}
+	}
+
+	// a record of 20012 bytes takes a page and two overflow pages, one of 9012 a page and one overflow page, the record after either starts a new page
+	records := []YCFileRecord{record(0, 100), record(1, 20000), record(2, 100), record(3, PAGESIZE-PAGEHEADERSIZE-12), record(4, 9000)}
+	writer, err := NewYCFileWriter(path)
+	require.NoError(t, err)
+	for _, r := range records {
+		require.NoError(t, writer.Write(r))
+	}
+	require.NoError(t, writer.Close())
+	writer, err = NewYCFileWriter(path) // appends after the overflow page ending the file
+	require.NoError(t, err)
+	records = append(records, record(5, 100))
+	require.NoError(t, writer.Write(records[5]))
+	require.NoError(t, writer.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(PAGESIZE*10), info.Size()) // a page of header, then 0 | 1 | 1 | 1 | 2 | 3 | 4 | 4 | 5
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	gzPath := path + ".gz"
+	f, err := os.Create(gzPath)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	for _, p := range []string{path, gzPath} {
+		reader, err := NewYCFileReader(p)
+		require.NoError(t, err)
+		defer reader.Close()
+		require.Equal(t, len(records), reader.RecordCount())
+		for _, expected := range records {
+			r, err := reader.Read()
+			require.NoError(t, err)
+			require.Equal(t, expected, r)
+		}
+		_, err = reader.Read()
+		require.Equal(t, io.EOF, err)
+
+		for _, i := range []int{4, 1, 2, 0, 5, 3} {
+			r, err := reader.ReadAt(i)
+			require.NoError(t, err)
+			require.Equal(t, records[i], r)
+		}
+	}
+
+	reader, err := NewYCFileReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	_, err = reader.ReadPage()
+	require.NoError(t, err)
+	page, err := reader.ReadPage()
+	require.NoError(t, err)
+	_, err = reader.PageRecords(page)
+	require.EqualError(t, err, "record 0 of the page runs on into overflow pages")
+	page, err = reader.ReadPage()
+	require.NoError(t, err)
+	ph, err := ParsePageHeader(page)
+	require.NoError(t, err)
+	require.Equal(t, PageHeader{FreeSpace: 0, Flags: PAGEOVERFLOW}, ph)
+
+	// fixed width records are found by arithmetic, so they still have to fit in a page
+	fields, fieldTypes := []string{}, []byte{}
+	for i := 0; i < 130; i++ {
+		fields, fieldTypes = append(fields, fmt.Sprint("f", i)), append(fieldTypes, STRINGLONG)
+	}
+	fixedPath := filepath.Join(dir, "wide")
+	require.NoError(t, CreateYCFile(fixedPath, fields, fieldTypes))
+	writer, err = NewYCFileWriter(fixedPath)
+	require.NoError(t, err)
+	defer writer.Close()
+	wide := YCFileRecord{}
+	for _, field := range fields {
+		wide.Data = append(wide.Data, FieldPair{Key: field, Val: "v"})
+	}
+	require.EqualError(t, writer.Write(wide), fmt.Sprintf("record of %d bytes doesn't fit in a page, which holds %d", 130*64, PAGESIZE-PAGEHEADERSIZE))
+}
+
+func TestLegacyFile(t *testing.T) {
+	path := t.TempDir() + "/movies"
+	b := append(bytes.Clone(LEGACYMAGICNUMBER), 0, 0, 0, 0, 0, 0, 0, 2, 2, STRINGSMALL, INT32)
+	for _, field := range []string{"title", "movieId"} {
+		name, err := castStringToFieldType(STRINGLONG, field)
+		require.NoError(t, err)
+		b = append(b, name...)
+	}
+	b = append(b, "Heat\n\n\n\n\n\n\n\n\n\n\n\n"...)
+	b = append(b, 0, 0, 0, 1)
+	b = append(b, "Sholay\n\n\n\n\n\n\n\n\n\n"...)
+	b = append(b, 0, 0, 0, 2)
+	require.NoError(t, os.WriteFile(path, b, 0666))
+
+	reader, err := NewYCFileReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	for run := 0; run < 2; run++ {
+		for i, title := range []string{"Heat", "Sholay"} {
+			r, err := reader.Read()
+			require.NoError(t, err)
+			require.Equal(t, YCFileRecord{Data: []FieldPair{{Key: "title", Val: title}, {Key: "movieId", Val: int32(i + 1)}}}, r)
+		}
+		_, err = reader.Read()
+		require.Equal(t, io.EOF, err)
+		require.NoError(t, reader.Rewind())
+	}
+
+	_, err = reader.ReadPage()
+	require.EqualError(t, err, "file has no pages, its records are stored contiguously after the header")
+}
+
+func TestRandomAccess(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, fieldTypes []byte, title func(i int) interface{}) (string, []YCFileRecord) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, CreateYCFile(path, []string{"movieId", "title"}, fieldTypes))
+		writer, err := NewYCFileWriter(path)
+		require.NoError(t, err)
+		defer writer.Close()
+		records := []YCFileRecord{}
+		for i := 0; i < 300; i++ {
+			record := YCFileRecord{Data: []FieldPair{{Key: "movieId", Val: int64(i)}, {Key: "title", Val: title(i)}}}
+			require.NoError(t, writer.Write(record))
+			records = append(records, record)
+		}
+		return path, records
+	}
+	gzipped := func(path string) string {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		f, err := os.Create(path + ".gz")
+		require.NoError(t, err)
+		defer f.Close()
+		gz := gzip.NewWriter(f)
+		_, err = gz.Write(b)
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		return path + ".gz"
+	}
+
+	// 73 byte records, 112 to a page
+	fixedPath, fixedRecords := writeFile("fixed", []byte{INT64, STRINGLONG | NULLABLE}, func(i int) interface{} {
+		if i%7 == 0 {
+			return nil
+		}
+		return fmt.Sprint(i)
+	})
+	variablePath, variableRecords := writeFile("variable", []byte{INT64, STRINGVAR}, func(i int) interface{} { return strings.Repeat("t", i%100) })
+	legacyPath := filepath.Join(dir, "legacy") // the fixed file without pages
+	b, err := os.ReadFile(fixedPath)
+	require.NoError(t, err)
+	reader, err := NewYCFileReader(fixedPath)
+	require.NoError(t, err)
+	legacy := append(bytes.Clone(LEGACYMAGICNUMBER), b[len(MAGICNUMBER):reader.ycf.computeSizeOfHeader()]...)
+	for _, record := range fixedRecords {
+		nulls := byte(0)
+		if record.Data[1].Val == nil {
+			nulls = 0b10
+		}
+		legacy = append(legacy, nulls)
+		legacy = binary.BigEndian.AppendUint64(legacy, uint64(record.Data[0].Val.(int64)))
+		title, _ := record.Data[1].Val.(string)
+		legacy = append(legacy, title+strings.Repeat(PADDINGBYTE, FIELDTYPESTOLENGTH[STRINGLONG]-len(title))...)
+	}
+	require.NoError(t, reader.Close())
+	require.NoError(t, os.WriteFile(legacyPath, legacy, 0666))
+
+	tc := []struct {
+		name    string
+		path    string
+		records []YCFileRecord
+	}{
+		{name: "fixed width pages", path: fixedPath, records: fixedRecords},
+		{name: "variable width pages", path: variablePath, records: variableRecords},
+		{name: "legacy", path: legacyPath, records: fixedRecords},
+		{name: "gzipped fixed width pages", path: gzipped(fixedPath), records: fixedRecords},
+		{name: "gzipped variable width pages", path: gzipped(variablePath), records: variableRecords},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := NewYCFileReader(test.path)
+			require.NoError(t, err)
+			defer reader.Close()
+			require.Equal(t, 300, reader.RecordCount())
+
+			for _, i := range []int{298, 0, 111, 112, 113, 150, 224, 1} {
+				record, err := reader.ReadAt(i)
+				require.NoError(t, err)
+				require.Equal(t, test.records[i], record)
+				record, err = reader.Read() // continues after it
+				require.NoError(t, err)
+				require.Equal(t, test.records[i+1], record)
+			}
+
+			records, err := reader.ReadRange(100, 20)
+			require.NoError(t, err)
+			require.Equal(t, test.records[100:120], records)
+			records, err = reader.ReadRange(290, 20)
+			require.NoError(t, err)
+			require.Equal(t, test.records[290:], records)
+
+			// records failing a filter are skipped, but only those before the end of a range are read
+			require.NoError(t, reader.SetFieldFilters([]FieldFilter{{Field: "movieId", Match: func(val []byte) bool { return binary.BigEndian.Uint64(val)%10 == 0 }}}))
+			records, err = reader.ReadRange(95, 20)
+			require.NoError(t, err)
+			require.Equal(t, []YCFileRecord{test.records[100], test.records[110]}, records)
+			record, err := reader.Read()
+			require.NoError(t, err)
+			require.Equal(t, test.records[120], record)
+			require.NoError(t, reader.Seek(291))
+			_, err = reader.ReadBefore(300)
+			require.Equal(t, io.EOF, err)
+			record, err = reader.ReadAt(101)
+			require.NoError(t, err)
+			require.Equal(t, test.records[101], record)
+			require.NoError(t, reader.SetFieldFilters(nil))
+
+			require.NoError(t, reader.Seek(300))
+			_, err = reader.Read()
+			require.Equal(t, io.EOF, err)
+			require.EqualError(t, reader.Seek(301), "record 301 out of range of 300 records")
+			require.EqualError(t, reader.Seek(-1), "record -1 out of range of 300 records")
+		})
+	}
+}